@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	assetDirectory = "assets"
+	assetManifest  = "manifest.jsonl"
+)
+
+var (
+	assets     *assetStore
+	assetsOnce sync.Once
+	assetsErr  error
+)
+
+type assetRecord struct {
+	URL         string `json:"url"`
+	Hash        string `json:"hash"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+type assetStore struct {
+	mu       sync.Mutex
+	dir      string
+	byURL    map[string]assetRecord
+	manifest *os.File
+}
+
+func openAssetStore(dir string) (*assetStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	store := &assetStore{dir: dir, byURL: make(map[string]assetRecord)}
+	manifestPath := filepath.Join(dir, assetManifest)
+	file, err := os.Open(manifestPath)
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record assetRecord
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				store.byURL[record.URL] = record
+			}
+		}
+		_ = file.Close()
+	}
+	store.manifest, err = os.OpenFile(manifestPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func assetStorage() (*assetStore, error) {
+	assetsOnce.Do(func() {
+		assets, assetsErr = openAssetStore(assetDirectory)
+	})
+	return assets, assetsErr
+}
+
+func closeAssetStore() {
+	if assets != nil {
+		err := assets.manifest.Close()
+		if err != nil {
+			logErrors(err)
+		}
+	}
+}
+
+func (store *assetStore) lookup(URL string) (assetRecord, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	record, ok := store.byURL[URL]
+	if !ok {
+		return record, false
+	}
+	_, err := os.Stat(record.Path)
+	return record, err == nil
+}
+
+func (store *assetStore) fetch(URL string) (assetRecord, error) {
+	if record, ok := store.lookup(URL); ok {
+		return record, nil
+	}
+	response, err := getWithRetry(URL, "")
+	if err != nil {
+		return assetRecord{}, err
+	}
+	defer response.Body.Close()
+	return store.save(URL, response.Header.Get("Content-Type"), response.Body)
+}
+
+func (store *assetStore) save(URL, contentType string, body io.Reader) (assetRecord, error) {
+	file, err := ioutil.TempFile(store.dir, "download-")
+	if err != nil {
+		return assetRecord{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), body)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return assetRecord{}, err
+	}
+	record := assetRecord{
+		URL:         URL,
+		Hash:        hex.EncodeToString(hash.Sum(nil)),
+		ContentType: contentType,
+		Size:        size,
+	}
+	record.Path = filepath.Join(store.dir, record.Hash+assetExtension(URL, contentType))
+	if _, err = os.Stat(record.Path); err == nil {
+		err = os.Remove(file.Name())
+	} else {
+		err = os.Rename(file.Name(), record.Path)
+	}
+	if err != nil {
+		return assetRecord{}, err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return assetRecord{}, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.byURL[URL] = record
+	_, err = store.manifest.Write(append(line, '\n'))
+	return record, err
+}
+
+func assetExtension(URL, contentType string) string {
+	uri, err := url.Parse(URL)
+	if err == nil {
+		ext := path.Ext(uri.Path)
+		if ext != "" && len(ext) <= 6 && !strings.ContainsAny(ext, "?&=") {
+			return ext
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		extensions, _ := mime.ExtensionsByType(mediaType)
+		if len(extensions) > 0 {
+			return extensions[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssetStoreSave(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openAssetStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.save("http://example.com/a.png", "image/png", strings.NewReader("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.save("http://example.com/b.png", "image/png", strings.NewReader("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := store.save("http://example.com/c.png", "image/png", strings.NewReader("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash != second.Hash || first.Path != second.Path {
+		t.Errorf("same content stored twice: %+v, %+v", first, second)
+	}
+	if other.Hash == first.Hash {
+		t.Errorf("different content got the same hash %s", other.Hash)
+	}
+	if first.Size != 4 || filepath.Base(first.Path) != first.Hash+".png" {
+		t.Errorf("save() = %+v", first)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("stored files = %v, want 2", files)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "download-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+	data, err := ioutil.ReadFile(first.Path)
+	if err != nil || string(data) != "same" {
+		t.Errorf("stored content = %q, %v", data, err)
+	}
+}
+
+func TestAssetStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openAssetStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.save("http://example.com/a.css", "text/css", strings.NewReader("body{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.manifest.WriteString("not json\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.manifest.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := openAssetStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.manifest.Close()
+	record, ok := reopened.lookup("http://example.com/a.css")
+	if !ok || record != saved {
+		t.Errorf("lookup() after reload = %+v, %v, want %+v", record, ok, saved)
+	}
+	if _, ok := reopened.lookup("http://example.com/missing.css"); ok {
+		t.Error("lookup() found an asset that was never saved")
+	}
+	record, err = reopened.fetch("http://example.com/a.css")
+	if err != nil || record != saved {
+		t.Errorf("fetch() of a stored asset = %+v, %v, want %+v", record, err, saved)
+	}
+}
+
+func TestAssetExtension(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		want        string
+	}{
+		{"http://example.com/logo.png", "", ".png"},
+		{"http://example.com/logo.png?size=2", "image/jpeg", ".png"},
+		{"http://example.com/report.PDF", "", ".PDF"},
+		{"http://example.com/image", "image/png", ".png"},
+		{"http://example.com/data", "application/json; charset=utf-8", ".json"},
+		{"http://example.com/file.verylongext", "", ""},
+		{"http://example.com/file", "", ""},
+		{"http://example.com/file", "bogus", ""},
+	}
+	for _, test := range tests {
+		if got := assetExtension(test.url, test.contentType); got != test.want {
+			t.Errorf("assetExtension(%q, %q) = %q, want %q", test.url, test.contentType, got, test.want)
+		}
+	}
+}
@@ -2,25 +2,28 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/target"
 	"github.com/chromedp/chromedp"
 	"github.com/dlclark/regexp2"
+	"golang.org/x/net/html/charset"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
-	"reflect"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -29,11 +32,9 @@ import (
 )
 
 var (
-	settings  settingsT
-	sitemap   scraping
-	startTime time.Time
-	img       int
-	rate      int
+	settings   settingsT
+	sitemap    scraping
+	sessionJar http.CookieJar
 )
 
 const (
@@ -41,50 +42,89 @@ const (
 )
 
 type selectors struct {
-	ID                 string   `json:"id,omitempty"`
-	Type               string   `json:"type,omitempty"`
-	ParentSelectors    []string `json:"parentSelectors,omitempty"`
-	Selector           string   `json:"selector,omitempty"`
-	Multiple           *bool    `json:"multiple,omitempty"`
-	Regex              string   `json:"regex,omitempty"`
-	Delay              *int     `json:"delay,omitempty"`
-	ExtractAttribute   string   `json:"extractAttribute,omitempty"`
+	ID               string   `json:"id,omitempty"`
+	Type             string   `json:"type,omitempty"`
+	ParentSelectors  []string `json:"parentSelectors,omitempty"`
+	Selector         string   `json:"selector,omitempty"`
+	SelectorKind     string   `json:"selectorKind,omitempty"`
+	Multiple         *bool    `json:"multiple,omitempty"`
+	Regex            string   `json:"regex,omitempty"`
+	Delay            *int     `json:"delay,omitempty"`
+	ExtractAttribute string   `json:"extractAttribute,omitempty"`
 	//Special Attribute data
-	Download           *bool    `json:"download,omitempty"`
-	AttributeName      string   `json:"attributeName,omitempty"`
-	HeaderRowSelector  string   `json:"headerRowSelector,omitempty"`
-	DataRowsSelector   string   `json:"dataRowsSelector,omitempty"`
-	SitemapURLs        []string `json:"sitemapUrls,omitempty"`
-	FoundUrlRegex      string   `json:"foundUrlRegex,omitempty"`
-	MinimumPriority    *float64 `json:"minimumPriority,omitempty"`
-	ClickSelector      string   `json:"clickSelector,omitempty"` //csl_tr
-	ClickType          string   `json:"clickType"` //cty_tr
-	ClickElementUnique string   `json:"clickElementUnique"` //ceu_tr
+	Download           *bool          `json:"download,omitempty"`
+	AttributeName      string         `json:"attributeName,omitempty"`
+	HeaderRowSelector  string         `json:"headerRowSelector,omitempty"`
+	DataRowsSelector   string         `json:"dataRowsSelector,omitempty"`
+	SitemapURLs        []string       `json:"sitemapUrls,omitempty"`
+	FoundUrlRegex      string         `json:"foundUrlRegex,omitempty"`
+	MinimumPriority    *float64       `json:"minimumPriority,omitempty"`
+	ClickSelector      string         `json:"clickSelector,omitempty"` //csl_tr
+	ClickType          string         `json:"clickType"`               //cty_tr
+	ClickElementUnique string         `json:"clickElementUnique"`      //ceu_tr
+	MaxClicks          *int           `json:"maxClicks,omitempty"`
+	ClickTimeout       *int           `json:"clickTimeout,omitempty"`
+	Wait               *waitCondition `json:"wait,omitempty"`
+	ResponseURLRegex   string         `json:"responseUrlRegex,omitempty"`
+	ResponseMethod     string         `json:"responseMethod,omitempty"`
+	JSONPath           string         `json:"jsonPath,omitempty"`
+	PDF                *bool          `json:"pdf,omitempty"`
+	MaxScrolls         *int           `json:"maxScrolls,omitempty"`
+	ScrollTimeout      *int           `json:"scrollTimeout,omitempty"`
 }
 
 type login struct {
-	URL      string `json:"url,omitempty"`
-	Username string `json:"username,omitempty"`
-	Password string `json:"password,omitempty"`
+	URL            string         `json:"url,omitempty"`
+	Username       string         `json:"username,omitempty"`
+	Password       string         `json:"password,omitempty"`
+	FormSelector   string         `json:"formSelector,omitempty"`
+	UsernameField  string         `json:"usernameField,omitempty"`
+	PasswordField  string         `json:"passwordField,omitempty"`
+	SubmitSelector string         `json:"submitSelector,omitempty"`
+	Wait           *waitCondition `json:"wait,omitempty"`
 }
 
 type scraping struct {
-	ID        string      `json:"projectID,omitempty"`
-	StartURL  []string    `json:"startURL,omitempty"`
-	Login     *login      `json:"login,omitempty"`
-	Selectors []selectors `json:"selectors,omitempty"`
+	ID              string         `json:"projectID,omitempty"`
+	StartURL        []string       `json:"startURL,omitempty"`
+	Login           *login         `json:"login,omitempty"`
+	Robots          string         `json:"robots,omitempty"`
+	MaxDepth        *int           `json:"maxDepth,omitempty"`
+	MaxPages        *int           `json:"maxPages,omitempty"`
+	AllowedDomains  []string       `json:"allowedDomains,omitempty"`
+	IncludePatterns []string       `json:"includePatterns,omitempty"`
+	ExcludePatterns []string       `json:"excludePatterns,omitempty"`
+	Wait            *waitCondition `json:"wait,omitempty"`
+	FullHTML        *bool          `json:"fullHTML,omitempty"`
+	Selectors       []selectors    `json:"selectors,omitempty"`
 }
 
 type settingsT struct {
-	Gui        bool     `json:"gui,omitempty"`
-	LogFile    string   `json:"logFile,omitempty"`
-	JavaScript *bool    `json:"javaScript,omitempty"`
-	Workers    int      `json:"workers,omitempty"`
-	RateLimit  *int     `json:"rateLimit,omitempty"`
-	OutputFile string   `json:"outputFile,omitempty"`
-	UserAgents []string `json:"userAgents,omitempty"`
-	Captcha    string   `json:"captcha,omitempty"`
-	Proxy      []string `json:"proxy,omitempty"`
+	Gui                 bool     `json:"gui,omitempty"`
+	LogFile             string   `json:"logFile,omitempty"`
+	JavaScript          *bool    `json:"javaScript,omitempty"`
+	Workers             int      `json:"workers,omitempty"`
+	RateLimit           *int     `json:"rateLimit,omitempty"`
+	OutputFile          string   `json:"outputFile,omitempty"`
+	UserAgents          []string `json:"userAgents,omitempty"`
+	Captcha             string   `json:"captcha,omitempty"`
+	Proxy               []string `json:"proxy,omitempty"`
+	StripParams         []string `json:"stripParams,omitempty"`
+	CanonicalLinks      *bool    `json:"canonicalLinks,omitempty"`
+	Retries             *int     `json:"retries,omitempty"`
+	RetryBackoff        *int     `json:"retryBackoff,omitempty"`
+	RequestsPerSecond   *float64 `json:"requestsPerSecond,omitempty"`
+	Burst               *int     `json:"burst,omitempty"`
+	HostConcurrency     *int     `json:"hostConcurrency,omitempty"`
+	ConnectTimeout      *int     `json:"connectTimeout,omitempty"`
+	ReadTimeout         *int     `json:"readTimeout,omitempty"`
+	Timeout             *int     `json:"timeout,omitempty"`
+	MaxIdleConnsPerHost *int     `json:"maxIdleConnsPerHost,omitempty"`
+	HTTP2               *bool    `json:"http2,omitempty"`
+	BrowserInstances    *int     `json:"browserInstances,omitempty"`
+	TabRecycle          *int     `json:"tabRecycle,omitempty"`
+	BlockResourceTypes  []string `json:"blockResourceTypes"`
+	BlockURLPatterns    []string `json:"blockUrlPatterns"`
 }
 
 type jsonType struct {
@@ -92,11 +132,64 @@ type jsonType struct {
 	Sitemap  scraping  `json:"sitemap"`
 }
 
+type sitemapXML struct {
+	Sitemaps []sitemapXMLEntry `xml:"sitemap"`
+	URLs     []sitemapXMLEntry `xml:"url"`
+}
+
+type sitemapXMLEntry struct {
+	Loc      string `xml:"loc"`
+	Priority string `xml:"priority"`
+}
+
+const (
+	defaultMaxClicks     = 50
+	defaultClickTimeout  = 2 * time.Minute
+	defaultMaxScrolls    = 50
+	defaultScrollPause   = time.Second
+	defaultScrollTimeout = 2 * time.Minute
+)
+
+var (
+	pageSelectorTypes = map[string]bool{
+		"SelectorElementClick":    true,
+		"SelectorElementScroll":   true,
+		"SelectorNetworkResponse": true,
+		"SelectorScreenshot":      true,
+	}
+)
+
+type renderOptions struct {
+	delay    time.Duration
+	waits    []*waitCondition
+	fullHTML bool
+	captures []*selectors
+	actions  []*selectors
+}
+
+type pageAction struct {
+	doc *goquery.Document
+	png []byte
+	pdf []byte
+	err error
+}
+
 type workerJob struct {
 	startURL   string
 	parent     string
+	depth      int
 	siteMap    *scraping
+	frontier   *frontier
 	linkOutput map[string]interface{}
+	state      string
+	errors     []jobError
+	userAgent  string
+	meta       pageMeta
+}
+
+func (job *workerJob) fail(stage, selectorID string, err error) {
+	logErrors(err)
+	job.errors = append(job.errors, newJobError(job.startURL, stage, selectorID, err))
 }
 
 type websiteData map[string]interface{}
@@ -217,7 +310,10 @@ func readJSON() {
 	if err != nil {
 		logErrors(err)
 	}
-	for i, e := range sitemap.Selectors {
+	for i, e := range jsonData.Sitemap.Selectors {
+		if e.Type == "SelectorSitemapXmlLink" {
+			e.Type = "SelectorSitemapXML"
+		}
 		if e.Download == nil {
 			e.Download = newBool(false)
 		}
@@ -238,6 +334,27 @@ func readJSON() {
 	if jsonData.Settings.RateLimit == nil {
 		jsonData.Settings.RateLimit = newInt(0)
 	}
+	if jsonData.Settings.StripParams == nil {
+		jsonData.Settings.StripParams = defaultStripParams
+	}
+	if jsonData.Settings.BlockResourceTypes == nil {
+		jsonData.Settings.BlockResourceTypes = defaultBlockResourceTypes
+	}
+	if jsonData.Settings.BlockURLPatterns == nil {
+		jsonData.Settings.BlockURLPatterns = defaultBlockURLPatterns
+	}
+	if jsonData.Settings.CanonicalLinks == nil {
+		jsonData.Settings.CanonicalLinks = newBool(false)
+	}
+	if jsonData.Settings.RequestsPerSecond == nil {
+		jsonData.Settings.RequestsPerSecond = newFloat64(0)
+	}
+	if jsonData.Settings.Burst == nil {
+		jsonData.Settings.Burst = newInt(1)
+	}
+	if jsonData.Settings.HostConcurrency == nil {
+		jsonData.Settings.HostConcurrency = newInt(0)
+	}
 	sitemap = jsonData.Sitemap
 	settings = jsonData.Settings
 }
@@ -265,6 +382,18 @@ func writeJSON() {
 	if jsonData.Settings.RateLimit != nil && *jsonData.Settings.RateLimit == 0 {
 		jsonData.Settings.RateLimit = nil
 	}
+	if jsonData.Settings.CanonicalLinks != nil && !*jsonData.Settings.CanonicalLinks {
+		jsonData.Settings.CanonicalLinks = nil
+	}
+	if jsonData.Settings.RequestsPerSecond != nil && *jsonData.Settings.RequestsPerSecond == 0 {
+		jsonData.Settings.RequestsPerSecond = nil
+	}
+	if jsonData.Settings.Burst != nil && *jsonData.Settings.Burst <= 1 {
+		jsonData.Settings.Burst = nil
+	}
+	if jsonData.Settings.HostConcurrency != nil && *jsonData.Settings.HostConcurrency == 0 {
+		jsonData.Settings.HostConcurrency = nil
+	}
 	dataJSON, err := json.MarshalIndent(jsonData, "", "  ")
 	if err != nil {
 		logErrors(err)
@@ -275,10 +404,10 @@ func writeJSON() {
 	}
 }
 
-func selectorText(doc *goquery.Document, selector *selectors) []string {
+func selectorText(scope *goquery.Selection, selector *selectors) []string {
 	var text []string
 	var matchText *regexp2.Match
-	doc.Find(selector.Selector).EachWithBreak(
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(
 		func(i int, s *goquery.Selection) bool {
 			if selector.Regex != "" {
 				re := regexp2.MustCompile(selector.Regex, 0)
@@ -298,24 +427,30 @@ func selectorText(doc *goquery.Document, selector *selectors) []string {
 	return text
 }
 
-func selectorLink(doc *goquery.Document, selector *selectors, baseURL string) []string {
+func selectorLink(scope *goquery.Selection, selector *selectors, baseURL string) ([]string, error) {
 	var links []string
-	doc.Find(selector.Selector).EachWithBreak(
+	var linkErr error
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(
 		func(i int, s *goquery.Selection) bool {
-			href, err := s.Attr("href")
-			if !err {
+			href, ok := s.Attr("href")
+			if !ok {
 				log.Println("Error: HREF not found")
 			}
-			links = append(links, toFixedURL(href, baseURL))
+			link, err := toFixedURL(href, baseURL)
+			if err != nil {
+				linkErr = err
+			} else {
+				links = append(links, link)
+			}
 			return *selector.Multiple
 		},
 	)
-	return links
+	return links, linkErr
 }
 
-func selectorElementAttribute(doc *goquery.Document, selector *selectors) []string {
+func selectorElementAttribute(scope *goquery.Selection, selector *selectors) []string {
 	var links []string
-	doc.Find(selector.Selector).EachWithBreak(
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(
 		func(i int, s *goquery.Selection) bool {
 			href, err := s.Attr(selector.ExtractAttribute)
 			if !err {
@@ -328,32 +463,11 @@ func selectorElementAttribute(doc *goquery.Document, selector *selectors) []stri
 	return links
 }
 
-func selectorElement(doc *goquery.Document, selector *selectors) []interface{} {
-	baseSiteMap := sitemap
+func selectorElement(scope *goquery.Selection, selector *selectors, job *workerJob) []interface{} {
 	var elementOutputList []interface{}
-	doc.Find(selector.Selector).EachWithBreak(
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(
 		func(i int, s *goquery.Selection) bool {
-			elementOutput := make(map[string]interface{})
-			for _, elementSelector := range baseSiteMap.Selectors {
-				if selector.ID == elementSelector.ParentSelectors[0] {
-					if elementSelector.Type == "SelectorText" {
-						resultText := s.Find(elementSelector.Selector).Text()
-						elementOutput[elementSelector.ID] = resultText
-					} else if elementSelector.Type == "SelectorImage" {
-						resultText, err := s.Find(elementSelector.Selector).Attr("src")
-						if !err {
-							log.Println("Error: HREF not found")
-						}
-						elementOutput[elementSelector.ID] = resultText
-					} else if elementSelector.Type == "SelectorLink" {
-						resultText, err := s.Find(elementSelector.Selector).Attr("href")
-						if !err {
-							log.Println("Error: HREF not found")
-						}
-						elementOutput[elementSelector.ID] = resultText
-					}
-				}
-			}
+			elementOutput := job.evaluateSelectors(s, selector.ID)
 			if len(elementOutput) != 0 {
 				elementOutputList = append(elementOutputList, elementOutput)
 			}
@@ -363,73 +477,304 @@ func selectorElement(doc *goquery.Document, selector *selectors) []interface{} {
 	return elementOutputList
 }
 
-func downloadFile(URL, fileName string) error {
-	response, err := http.Get(URL)
+func downloadAsset(URL string) (assetRecord, error) {
+	store, err := assetStorage()
 	if err != nil {
-		return err
+		return assetRecord{}, err
 	}
-	if response.StatusCode != 200 {
-		return errors.New("code not 200")
+	return store.fetch(URL)
+}
+
+func assetOutput(URL string, selector *selectors) (interface{}, error) {
+	if !*selector.Download {
+		return URL, nil
 	}
-	file, err := os.Create(fileName)
+	record, err := downloadAsset(URL)
 	if err != nil {
-		return err
+		return map[string]interface{}{"url": URL}, err
 	}
-	_, err = io.Copy(file, response.Body)
-	if err != nil {
-		return err
+	return map[string]interface{}{"url": URL, "hash": record.Hash, "path": record.Path}, nil
+}
+
+func assetOutputs(hrefs []string, selector *selectors, baseURL string) ([]interface{}, error) {
+	var outputs []interface{}
+	var assetErr error
+	for _, href := range hrefs {
+		link, err := toFixedURL(href, baseURL)
+		if err != nil {
+			assetErr = err
+			continue
+		}
+		output, err := assetOutput(link, selector)
+		if err != nil {
+			assetErr = err
+		}
+		outputs = append(outputs, output)
 	}
-	err = file.Close()
-	if err != nil {
-		_ = response.Body.Close()
-		return err
+	return outputs, assetErr
+}
+
+func imageSource(s *goquery.Selection) string {
+	var src string
+	var best float64
+	for _, candidate := range strings.Split(s.AttrOr("srcset", ""), ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		size := 1.0
+		if len(fields) > 1 {
+			descriptor := fields[1]
+			value, err := strconv.ParseFloat(descriptor[:len(descriptor)-1], 64)
+			if err == nil {
+				size = value
+			}
+		}
+		if size > best {
+			best = size
+			src = fields[0]
+		}
 	}
-	err = response.Body.Close()
-	return err
+	if src == "" {
+		src = s.AttrOr("src", "")
+	}
+	return src
 }
 
-func selectorImage(doc *goquery.Document, selector *selectors) []string {
+func selectorImage(scope *goquery.Selection, selector *selectors, baseURL string) ([]interface{}, error) {
 	var sources []string
-	doc.Find(selector.Selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
-		src, ok := s.Attr("src")
-		if ok {
-			err := downloadFile(src, "assets/"+strconv.Itoa(img)+src[strings.LastIndex(src, "."):])
-			logErrors(err)
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		src := imageSource(s)
+		if src != "" {
+			sources = append(sources, src)
 		} else {
 			fmt.Println("Error: SRC has not been found.")
 		}
-		sources = append(sources, src)
 		return *selector.Multiple
 	})
-	return sources
+	return assetOutputs(sources, selector, baseURL)
 }
 
-func selectorTable(doc *goquery.Document, selector *selectors) map[string]interface{} {
-	var headings, row []string
-	var rows [][]string
-	table := make(map[string]interface{})
-	doc.Find(selector.Selector).Each(func(_ int, tableHTML *goquery.Selection) {
-		tableHTML.Find("tr").Each(func(_ int, rowHTML *goquery.Selection) {
-			rowHTML.Find("th").Each(func(_ int, tableHeading *goquery.Selection) {
-				headings = append(headings, tableHeading.Text())
-			})
-			rowHTML.Find("td").Each(func(_ int, tableCell *goquery.Selection) {
-				row = append(row, tableCell.Text())
-			})
-			if len(row) != 0 {
-				rows = append(rows, row)
-				row = nil
+func selectorFile(scope *goquery.Selection, selector *selectors, baseURL string) ([]interface{}, error) {
+	var files []string
+	selectorFind(scope, selector, selector.Selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		href, ok := s.Attr("href")
+		if ok {
+			files = append(files, href)
+		} else {
+			fmt.Println("Error: HREF has not been found.")
+		}
+		return *selector.Multiple
+	})
+	return assetOutputs(files, selector, baseURL)
+}
+
+type tableSpan struct {
+	text string
+	rows int
+}
+
+func tableGrid(rows *goquery.Selection) [][]string {
+	var grid [][]string
+	spans := make(map[int]*tableSpan)
+	rows.Each(func(_ int, rowHTML *goquery.Selection) {
+		var line []string
+		fillSpans := func() {
+			for span := spans[len(line)]; span != nil && span.rows > 0; span = spans[len(line)] {
+				line = append(line, span.text)
+				span.rows--
+			}
+		}
+		rowHTML.ChildrenFiltered("th, td").Each(func(_ int, tableCell *goquery.Selection) {
+			fillSpans()
+			text := strings.TrimSpace(tableCell.Text())
+			colspan, err := strconv.Atoi(tableCell.AttrOr("colspan", "1"))
+			if err != nil || colspan < 1 {
+				colspan = 1
+			}
+			rowspan, err := strconv.Atoi(tableCell.AttrOr("rowspan", "1"))
+			if err != nil || rowspan < 1 {
+				rowspan = 1
+			}
+			for i := 0; i < colspan; i++ {
+				if rowspan > 1 {
+					spans[len(line)] = &tableSpan{text: text, rows: rowspan - 1}
+				}
+				line = append(line, text)
 			}
 		})
+		for column := range spans {
+			if column >= len(line) {
+				fillSpans()
+			}
+		}
+		if len(line) != 0 {
+			grid = append(grid, line)
+		}
+	})
+	return grid
+}
+
+func tableHeadings(headerRows [][]string, columns int) []string {
+	headings := make([]string, columns)
+	seen := make(map[string]int)
+	for column := range headings {
+		var parts []string
+		for _, row := range headerRows {
+			if column < len(row) && row[column] != "" && (len(parts) == 0 || parts[len(parts)-1] != row[column]) {
+				parts = append(parts, row[column])
+			}
+		}
+		heading := strings.Join(parts, " ")
+		if heading == "" {
+			heading = "column" + strconv.Itoa(column+1)
+		}
+		seen[heading]++
+		if seen[heading] > 1 {
+			heading += "_" + strconv.Itoa(seen[heading])
+		}
+		headings[column] = heading
+	}
+	return headings
+}
+
+func selectorTable(scope *goquery.Selection, selector *selectors) []interface{} {
+	var records []interface{}
+	selectorFind(scope, selector, selector.Selector).Each(func(_ int, tableHTML *goquery.Selection) {
+		var headerHTML *goquery.Selection
+		if selector.HeaderRowSelector != "" {
+			headerHTML = selectorFind(tableHTML, selector, selector.HeaderRowSelector)
+		} else if tableHTML.Find("thead tr").Length() > 0 {
+			headerHTML = tableHTML.Find("thead tr")
+		} else {
+			headerHTML = tableHTML.Find("tr").First().FilterFunction(func(_ int, rowHTML *goquery.Selection) bool {
+				return rowHTML.ChildrenFiltered("th").Length() > 0 && rowHTML.ChildrenFiltered("td").Length() == 0
+			})
+		}
+		dataRowsHTML := tableHTML.Find("tr")
+		if selector.DataRowsSelector != "" {
+			dataRowsHTML = selectorFind(tableHTML, selector, selector.DataRowsSelector)
+		}
+		headerRows := tableGrid(headerHTML)
+		rows := tableGrid(dataRowsHTML.NotSelection(headerHTML))
+		columns := 0
+		for _, row := range append(headerRows, rows...) {
+			if len(row) > columns {
+				columns = len(row)
+			}
+		}
+		headings := tableHeadings(headerRows, columns)
+		for _, row := range rows {
+			record := make(map[string]interface{})
+			for column, value := range row {
+				record[headings[column]] = value
+			}
+			records = append(records, record)
+		}
 	})
-	table["header"] = headings
-	table["rows"] = rows
-	return table
+	return records
+}
+
+func fetchSitemapXML(sitemapURL string, visited map[string]bool) ([]sitemapXMLEntry, error) {
+	if visited[sitemapURL] {
+		return nil, nil
+	}
+	visited[sitemapURL] = true
+	response, err := getWithRetry(sitemapURL, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var data sitemapXML
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	err = decoder.Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	entries := data.URLs
+	var indexErr error
+	for _, index := range data.Sitemaps {
+		loc, err := toFixedURL(strings.TrimSpace(index.Loc), sitemapURL)
+		if err != nil {
+			indexErr = err
+			continue
+		}
+		children, err := fetchSitemapXML(loc, visited)
+		if err != nil {
+			indexErr = err
+		}
+		entries = append(entries, children...)
+	}
+	return entries, indexErr
+}
+
+func selectorSitemapXML(selector *selectors) ([]string, error) {
+	var links []string
+	var re *regexp2.Regexp
+	var err, sitemapErr error
+	if selector.FoundUrlRegex != "" {
+		re, err = regexp2.Compile(selector.FoundUrlRegex, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	visited := make(map[string]bool)
+	for _, sitemapURL := range selector.SitemapURLs {
+		entries, err := fetchSitemapXML(sitemapURL, visited)
+		if err != nil {
+			sitemapErr = err
+		}
+		for _, entry := range entries {
+			loc := strings.TrimSpace(entry.Loc)
+			if loc == "" {
+				continue
+			}
+			if re != nil {
+				match, _ := re.MatchString(loc)
+				if !match {
+					continue
+				}
+			}
+			if selector.MinimumPriority != nil && *selector.MinimumPriority > 0 {
+				priority := 0.5
+				if entry.Priority != "" {
+					priority, _ = strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64)
+				}
+				if priority < *selector.MinimumPriority {
+					continue
+				}
+			}
+			link, err := toFixedURL(loc, sitemapURL)
+			if err != nil {
+				sitemapErr = err
+				continue
+			}
+			links = append(links, link)
+		}
+	}
+	return links, sitemapErr
 }
 
 func parseCatchAudio(url string) (string, error) {
 	var speechBody speechRecognitionResponse
-	resp, err := http.Get(url)
+	netClient := httpClient()
+	resp, err := netClient.Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -448,7 +793,11 @@ func parseCatchAudio(url string) (string, error) {
 		},
 	}
 	reqBody, err := json.Marshal(audioBody)
-	speechResp, err := http.Post("https://speech.googleapis.com/v1p1beta1/speech:recognize?key="+settings.Captcha, "application/json", bytes.NewBuffer(reqBody))
+	if err != nil {
+		_ = resp.Body.Close()
+		return "", err
+	}
+	speechResp, err := netClient.Post("https://speech.googleapis.com/v1p1beta1/speech:recognize?key="+settings.Captcha, "application/json", bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", err
 	}
@@ -456,6 +805,11 @@ func parseCatchAudio(url string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if len(speechBody.Result) == 0 || len(speechBody.Result[0].Alternatives) == 0 {
+		_ = speechResp.Body.Close()
+		_ = resp.Body.Close()
+		return "", fmt.Errorf("no transcript for %s", url)
+	}
 	err = speechResp.Body.Close()
 	if err != nil {
 		_ = resp.Body.Close()
@@ -465,50 +819,38 @@ func parseCatchAudio(url string) (string, error) {
 	return speechBody.Result[0].Alternatives[0].Transcript, err
 }
 
-func crawlURL(href, userAgent string) *goquery.Document {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	if len(settings.Proxy) > 0 {
-		proxyString := settings.Proxy[0]
-		proxyURL, _ := url.Parse(proxyString)
-		transport.Proxy = http.ProxyURL(proxyURL)
-	}
-	netClient := &http.Client{Transport: transport}
-	req, err := http.NewRequest(http.MethodGet, href, nil)
-	if err != nil {
-		logErrors(err)
-		os.Exit(1)
-	}
-	if len(userAgent) > 0 {
-		req.Header.Set("User-Agent", userAgent)
-	}
-	response, err := netClient.Do(req)
+func crawlURL(href, userAgent string) (*goquery.Document, pageMeta, error) {
+	response, err := getWithRetry(href, userAgent)
 	if err != nil {
-		logErrors(err)
-		os.Exit(1)
+		return nil, pageMeta{}, err
 	}
-	doc, err := goquery.NewDocumentFromReader(response.Body)
-	err = response.Body.Close()
+	defer func() {
+		closeErr := response.Body.Close()
+		if closeErr != nil {
+			frontendLog(closeErr)
+		}
+	}()
+	meta := pageMeta{StatusCode: response.StatusCode, ContentType: response.Header.Get("Content-Type")}
+	body, name, err := decodeBody(response.Body, meta.ContentType)
 	if err != nil {
-		frontendLog(err)
+		return nil, meta, err
 	}
-	return doc
+	meta.Charset = name
+	doc, err := goquery.NewDocumentFromReader(body)
+	return doc, meta, err
 }
 
-func toFixedURL(href, baseURL string) string {
+func toFixedURL(href, baseURL string) (string, error) {
 	uri, err := url.Parse(href)
 	if err != nil {
-		logErrors(err)
-		os.Exit(1)
+		return "", err
 	}
 	base, err := url.Parse(baseURL)
 	if err != nil {
-		logErrors(err)
-		os.Exit(1)
+		return "", err
 	}
 	toFixedURI := base.ResolveReference(uri)
-	return toFixedURI.String()
+	return toFixedURI.String(), nil
 }
 
 func getSiteMap(startURL []string, selector *selectors) *scraping {
@@ -516,86 +858,246 @@ func getSiteMap(startURL []string, selector *selectors) *scraping {
 	newSiteMap := new(scraping)
 	newSiteMap.ID = selector.ID
 	newSiteMap.StartURL = startURL
+	newSiteMap.Wait = baseSiteMap.Wait
+	newSiteMap.FullHTML = baseSiteMap.FullHTML
 	newSiteMap.Selectors = baseSiteMap.Selectors
 	return newSiteMap
 }
 
+func selectorDelay(selector *selectors) time.Duration {
+	if selector.Delay == nil || *selector.Delay <= 0 {
+		return 0
+	}
+	return time.Duration(*selector.Delay) * time.Millisecond
+}
+
+func pageDelay(siteMap *scraping, parent string) time.Duration {
+	var delay time.Duration
+	for _, selector := range siteMap.Selectors {
+		if hasParent(&selector, parent) {
+			if selectorDelay(&selector) > delay {
+				delay = selectorDelay(&selector)
+			}
+		}
+	}
+	return delay
+}
+
+func pageRenderOptions(siteMap *scraping, parent string) renderOptions {
+	options := renderOptions{
+		delay:    pageDelay(siteMap, parent),
+		waits:    pageWaits(siteMap, parent),
+		fullHTML: siteMap.FullHTML != nil && *siteMap.FullHTML,
+	}
+	for i, selector := range siteMap.Selectors {
+		if !hasParent(&selector, parent) {
+			continue
+		}
+		switch selector.Type {
+		case "SelectorNetworkResponse":
+			options.captures = append(options.captures, &siteMap.Selectors[i])
+		case "SelectorElementClick", "SelectorElementScroll", "SelectorScreenshot":
+			options.actions = append(options.actions, &siteMap.Selectors[i])
+		}
+	}
+	return options
+}
+
+func hasParent(selector *selectors, parent string) bool {
+	return contains(selector.ParentSelectors, parent)
+}
+
 func getChildSelector(selector *selectors) bool {
 	count := 0
 	for _, childSelector := range sitemap.Selectors {
-		if selector.ID == childSelector.ParentSelectors[0] {
+		if hasParent(&childSelector, selector.ID) {
 			count++
 		}
 	}
 	return count == 0
 }
 
-func hasElement(s interface{}, elem interface{}) bool {
-	arrV := reflect.ValueOf(s)
-	if arrV.Kind() == reflect.Slice {
-		for i := 0; i < arrV.Len(); i++ {
-			if arrV.Index(i).Interface() == elem {
-				return true
+func validateParents(siteMap scraping) error {
+	types := map[string]string{"_root": ""}
+	for _, selector := range siteMap.Selectors {
+		types[selector.ID] = selector.Type
+	}
+	rooted := false
+	for _, selector := range siteMap.Selectors {
+		parents := 0
+		for _, parent := range selector.ParentSelectors {
+			if strings.TrimSpace(parent) == "" {
+				continue
+			}
+			if parent == selector.ID && selector.Type != "SelectorLink" {
+				return fmt.Errorf("selector %q lists itself as a parent; only link selectors can, for pagination", selector.ID)
+			}
+			parentType, ok := types[parent]
+			if !ok {
+				return fmt.Errorf("selector %q has unknown parent selector %q", selector.ID, parent)
+			}
+			if pageSelectorTypes[selector.Type] && parent != "_root" && parentType != "SelectorLink" && parentType != "SelectorSitemapXML" {
+				return fmt.Errorf("selector %q (%s) works on a whole page and can only have _root or a link selector as a parent, not %q", selector.ID, selector.Type, parent)
+			}
+			parents++
+		}
+		if parents == 0 {
+			return fmt.Errorf("selector %q has no parent selectors", selector.ID)
+		}
+		rooted = rooted || hasParent(&selector, "_root")
+	}
+	if len(siteMap.Selectors) > 0 && !rooted {
+		return fmt.Errorf("no selector has _root as a parent")
+	}
+	return parentCycles(siteMap)
+}
+
+// Cycles through a link or sitemap selector load a new page on every pass, so the
+// visited set and the depth limit end them; only cycles within one page are rejected.
+func parentCycles(siteMap scraping) error {
+	types := make(map[string]string)
+	for _, selector := range siteMap.Selectors {
+		types[selector.ID] = selector.Type
+	}
+	children := make(map[string][]string)
+	for _, selector := range siteMap.Selectors {
+		for _, parent := range selector.ParentSelectors {
+			if types[parent] != "SelectorLink" && types[parent] != "SelectorSitemapXML" {
+				children[parent] = append(children[parent], selector.ID)
+			}
+		}
+	}
+	visiting, done := make(map[string]bool), make(map[string]bool)
+	var visit func(id string) error
+	visit = func(id string) error {
+		if visiting[id] {
+			return fmt.Errorf("selector %q is part of a parent selector cycle", id)
+		}
+		if done[id] {
+			return nil
+		}
+		visiting[id] = true
+		for _, child := range children[id] {
+			err := visit(child)
+			if err != nil {
+				return err
 			}
 		}
+		visiting[id], done[id] = false, true
+		return nil
+	}
+	for _, selector := range siteMap.Selectors {
+		err := visit(selector.ID)
+		if err != nil {
+			return err
+		}
 	}
-	return false
+	return nil
 }
 
-func emulateURL(url, userAgent string) *goquery.Document {
-	var opts []func(*chromedp.ExecAllocator)
+func newBrowserContext(userAgent string) (context.Context, context.CancelFunc) {
+	proxy := ""
 	if len(settings.Proxy) > 0 {
-		proxyString := settings.Proxy[0]
-		proxyServer := chromedp.ProxyServer(proxyString)
-		opts = append(chromedp.DefaultExecAllocatorOptions[:], proxyServer)
-	} else {
-		opts = append(chromedp.DefaultExecAllocatorOptions[:])
+		proxy = settings.Proxy[0]
 	}
-	if len(userAgent) > 0 {
-		opts = append(opts, chromedp.UserAgent(userAgent))
+	bCtx, cancelAllocator := chromedp.NewExecAllocator(context.Background(), browserAllocatorOptions(proxy, userAgent)...)
+	ctx, cancelContext := chromedp.NewContext(bCtx)
+	return ctx, func() {
+		cancelContext()
+		cancelAllocator()
 	}
-	bCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	ctx, _ := chromedp.NewContext(bCtx)
-	defer cancel()
-	var err error
+}
+
+func setBrowserCookies(pageURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if sessionJar == nil {
+			return nil
+		}
+		uri, err := url.Parse(pageURL)
+		if err != nil {
+			return err
+		}
+		for _, cookie := range sessionJar.Cookies(uri) {
+			_, err = network.SetCookie(cookie.Name, cookie.Value).WithURL(pageURL).Do(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func emulateURL(url, userAgent string, options renderOptions) (*goquery.Document, pageMeta, error) {
 	var body string
-	err = chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.InnerHTML(`body`, &body, chromedp.NodeVisible, chromedp.ByQuery),
-	)
-	r := strings.NewReader(body)
-	doc, err := goquery.NewDocumentFromReader(r)
+	var meta pageMeta
+	capture, err := newResponseCapture(options.captures)
 	if err != nil {
-		logErrors(err)
-		os.Exit(1)
+		return nil, meta, err
+	}
+	tab, err := crawlBrowsers.acquire(userAgent)
+	if err != nil {
+		return nil, meta, err
+	}
+	err = runWithRetry(url, userAgent, func() error {
+		idle, stopWatching := watchNetworkIdle(tab.ctx)
+		defer stopWatching()
+		stopCapture := capture.watch(tab.ctx)
+		defer stopCapture()
+		document, stopDocument := watchDocument(tab.ctx)
+		defer stopDocument()
+		return chromedp.Run(tab.ctx,
+			setBrowserCookies(url),
+			enableLifecycleEvents(),
+			capture.enable(),
+			document.enable(),
+			chromedp.Navigate(url),
+			document.check(url, &meta.StatusCode),
+			waitActions(options.waits, idle),
+			chromedp.Sleep(options.delay),
+			snapshotHTML(options.fullHTML, &body),
+			chromedp.Evaluate(`document.contentType`, &meta.ContentType),
+			chromedp.Evaluate(`document.characterSet.toLowerCase()`, &meta.Charset),
+			capture.collect(),
+		)
+	})
+	if err == nil {
+		meta.Actions = runPageActions(tab.ctx, options.actions)
+	}
+	crawlBrowsers.release(tab)
+	if err != nil {
+		return nil, meta, err
 	}
-	return doc
+	meta.Responses = capture.responses
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	return doc, meta, err
 }
 
-func navigateURL(url, userAgent string) *goquery.Document {
-	var opts []func(*chromedp.ExecAllocator)
-	if len(settings.Proxy) > 0 {
-		proxyString := settings.Proxy[0]
-		proxyServer := chromedp.ProxyServer(proxyString)
-		opts = append(chromedp.DefaultExecAllocatorOptions[:], proxyServer)
-	} else {
-		opts = append(chromedp.DefaultExecAllocatorOptions[:])
-	}
-	if len(userAgent) > 0 {
-		opts = append(opts, chromedp.UserAgent(userAgent))
+func navigateURL(url, userAgent string, options renderOptions) (*goquery.Document, pageMeta, error) {
+	var meta pageMeta
+	tab, err := crawlBrowsers.acquire(userAgent)
+	if err != nil {
+		return nil, meta, err
 	}
-	bCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	ctx, cancel := chromedp.NewContext(bCtx)
-	defer cancel()
+	defer crawlBrowsers.release(tab)
+	ctx := tab.ctx
 	var checkboxNode *target.Info
 	var challengeNode *target.Info
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.WaitReady("iframe", chromedp.ByQuery),
-	)
+	idle, stopWatching := watchNetworkIdle(ctx)
+	defer stopWatching()
+	err = runWithRetry(url, userAgent, func() error {
+		document, stopDocument := watchDocument(ctx)
+		defer stopDocument()
+		return chromedp.Run(ctx,
+			setBrowserCookies(url),
+			enableLifecycleEvents(),
+			document.enable(),
+			chromedp.Navigate(url),
+			document.check(url, &meta.StatusCode),
+			chromedp.WaitReady("iframe", chromedp.ByQuery),
+		)
+	})
 	if err != nil {
-		logErrors(err)
-		os.Exit(0)
+		return nil, meta, err
 	}
 	targets, _ := chromedp.Targets(ctx)
 	for _, t := range targets {
@@ -606,12 +1108,10 @@ func navigateURL(url, userAgent string) *goquery.Document {
 			challengeNode = t
 		}
 	}
-	var iCtx context.Context
 	if checkboxNode == nil {
-		logErrors(fmt.Errorf("checkboxNode is nil"))
-	} else {
-		iCtx, _ = chromedp.NewContext(ctx, chromedp.WithTargetID(checkboxNode.TargetID))
+		return nil, meta, fmt.Errorf("checkboxNode is nil")
 	}
+	iCtx, _ := chromedp.NewContext(ctx, chromedp.WithTargetID(checkboxNode.TargetID))
 	var ok bool
 	var checked string
 	err = chromedp.Run(
@@ -619,23 +1119,23 @@ func navigateURL(url, userAgent string) *goquery.Document {
 		chromedp.WaitVisible(`#recaptcha-anchor`, chromedp.NodeVisible),
 		chromedp.Click(`#recaptcha-anchor`, chromedp.ByID),
 	)
+	if err != nil {
+		return nil, meta, err
+	}
 	err = chromedp.Run(
 		iCtx,
 		chromedp.AttributeValue(`#recaptcha-anchor`, "aria-checked", &checked, &ok),
 	)
 	if err != nil {
-		logErrors(err)
-		os.Exit(0)
+		return nil, meta, err
 	}
 	isChecked, _ := strconv.ParseBool(checked)
 	if !isChecked {
 		var audioSource string
-		var iCtx2 context.Context
 		if challengeNode == nil {
-			logErrors(fmt.Errorf("challengeNode is nil"))
-		} else {
-			iCtx2, _ = chromedp.NewContext(ctx, chromedp.WithTargetID(challengeNode.TargetID))
+			return nil, meta, fmt.Errorf("challengeNode is nil")
 		}
+		iCtx2, _ := chromedp.NewContext(ctx, chromedp.WithTargetID(challengeNode.TargetID))
 		err = chromedp.Run(
 			iCtx2,
 			chromedp.WaitVisible(`#recaptcha-audio-button`, chromedp.ByID),
@@ -644,14 +1144,12 @@ func navigateURL(url, userAgent string) *goquery.Document {
 			chromedp.AttributeValue(`#audio-source`, "src", &audioSource, &ok),
 		)
 		if err != nil {
-			logErrors(err)
-			os.Exit(0)
+			return nil, meta, err
 		}
 		if audioSource != "" {
 			text, err := parseCatchAudio(audioSource)
 			if err != nil {
-				logErrors(err)
-				os.Exit(0)
+				return nil, meta, err
 			}
 			err = chromedp.Run(
 				iCtx2,
@@ -659,19 +1157,402 @@ func navigateURL(url, userAgent string) *goquery.Document {
 				chromedp.SetValue(`#audio-response`, text, chromedp.ByID),
 				chromedp.Click(`#recaptcha-verify-button`, chromedp.NodeVisible),
 			)
+			if err != nil {
+				return nil, meta, err
+			}
 		}
 	}
 	var body string
 	err = chromedp.Run(ctx,
-		chromedp.InnerHTML(`body`, &body, chromedp.NodeVisible, chromedp.ByQuery),
+		waitActions(options.waits, idle),
+		chromedp.Sleep(options.delay),
+		snapshotHTML(options.fullHTML, &body),
 	)
+	if err != nil {
+		return nil, meta, err
+	}
+	meta.Actions = runPageActions(ctx, options.actions)
 	r := strings.NewReader(body)
 	doc, err := goquery.NewDocumentFromReader(r)
+	return doc, meta, err
+}
+
+func clickElementKey(ctx context.Context, node *cdp.Node, uniqueness string) (string, error) {
+	var text, html string
+	switch uniqueness {
+	case "css":
+		return node.FullXPath(), nil
+	case "html":
+		err := chromedp.Run(ctx, chromedp.OuterHTML([]cdp.NodeID{node.NodeID}, &html, chromedp.ByNodeID))
+		return html, err
+	case "htmlText":
+		err := chromedp.Run(ctx,
+			chromedp.OuterHTML([]cdp.NodeID{node.NodeID}, &html, chromedp.ByNodeID),
+			chromedp.TextContent([]cdp.NodeID{node.NodeID}, &text, chromedp.ByNodeID),
+		)
+		return html + text, err
+	default:
+		err := chromedp.Run(ctx, chromedp.TextContent([]cdp.NodeID{node.NodeID}, &text, chromedp.ByNodeID))
+		return strings.TrimSpace(text), err
+	}
+}
+
+func countElements(ctx context.Context, selector *selectors) int {
+	var count int
+	query, _ := json.Marshal(selector.Selector)
+	expression := `document.querySelectorAll(` + string(query) + `).length`
+	if isXPath(selector) {
+		expression = `document.evaluate(` + string(query) + `, document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null).snapshotLength`
+	}
+	err := chromedp.Run(ctx, chromedp.Evaluate(expression, &count))
 	if err != nil {
 		logErrors(err)
-		os.Exit(0)
 	}
-	return doc
+	return count
+}
+
+func openSelectorPage(ctx context.Context, pageURL, userAgent string, selector *selectors, actions ...chromedp.Action) error {
+	var waits []*waitCondition
+	if selector.Wait != nil && selector.Wait.Type != "" {
+		waits = append(waits, selector.Wait)
+	}
+	var status int
+	return runWithRetry(pageURL, userAgent, func() error {
+		idle, stopWatching := watchNetworkIdle(ctx)
+		defer stopWatching()
+		document, stopDocument := watchDocument(ctx)
+		defer stopDocument()
+		return chromedp.Run(ctx, append([]chromedp.Action{
+			setBrowserCookies(pageURL),
+			enableLifecycleEvents(),
+			document.enable(),
+			chromedp.Navigate(pageURL),
+			document.check(pageURL, &status),
+			chromedp.WaitVisible(`body`, chromedp.ByQuery),
+			waitActions(waits, idle),
+			chromedp.Sleep(selectorDelay(selector)),
+		}, actions...)...)
+	})
+}
+
+func tabDocument(ctx context.Context) (*goquery.Document, error) {
+	var body string
+	err := chromedp.Run(ctx, chromedp.InnerHTML(`body`, &body, chromedp.ByQuery))
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(strings.NewReader(body))
+}
+
+func clickElements(ctx context.Context, selector *selectors) error {
+	err := compileXPath(selector, selector.ClickSelector)
+	if err != nil {
+		return err
+	}
+	delay := selectorDelay(selector)
+	maxClicks := defaultMaxClicks
+	if selector.MaxClicks != nil && *selector.MaxClicks > 0 {
+		maxClicks = *selector.MaxClicks
+	}
+	timeout := defaultClickTimeout
+	if selector.ClickTimeout != nil && *selector.ClickTimeout > 0 {
+		timeout = time.Duration(*selector.ClickTimeout) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+	clicks := 0
+	clicked := make(map[string]bool)
+	for clicks < maxClicks && time.Now().Before(deadline) {
+		var buttons []*cdp.Node
+		var query string
+		err = chromedp.Run(ctx, markXPath(selector, selector.ClickSelector, &query))
+		if err != nil {
+			return err
+		}
+		err = chromedp.Run(ctx, chromedp.Nodes(query, &buttons, chromedp.ByQueryAll, chromedp.AtLeast(0)))
+		if err != nil {
+			logErrors(err)
+			break
+		}
+		before := countElements(ctx, selector)
+		clickedAny := false
+		if selector.ClickType == "more" {
+			clicked = make(map[string]bool)
+		}
+		for _, button := range buttons {
+			if clicks >= maxClicks || !time.Now().Before(deadline) {
+				break
+			}
+			key, err := clickElementKey(ctx, button, selector.ClickElementUnique)
+			if err != nil || clicked[key] {
+				continue
+			}
+			clicked[key] = true
+			clicks++
+			err = chromedp.Run(ctx, chromedp.MouseClickNode(button), chromedp.Sleep(delay))
+			if err != nil {
+				logErrors(err)
+				continue
+			}
+			clickedAny = true
+		}
+		if !clickedAny {
+			break
+		}
+		if selector.ClickType == "more" && countElements(ctx, selector) <= before {
+			break
+		}
+	}
+	return nil
+}
+
+func selectorElementClick(job *workerJob, selector *selectors) ([]interface{}, error) {
+	action := job.pageAction(selector)
+	if action.err != nil {
+		return nil, action.err
+	}
+	return selectorElement(action.doc.Selection, selector, job), nil
+}
+
+func scrollElements(ctx context.Context, selector *selectors) error {
+	pause := selectorDelay(selector)
+	if pause <= 0 {
+		pause = defaultScrollPause
+	}
+	maxScrolls := defaultMaxScrolls
+	if selector.MaxScrolls != nil && *selector.MaxScrolls > 0 {
+		maxScrolls = *selector.MaxScrolls
+	}
+	timeout := defaultScrollTimeout
+	if selector.ScrollTimeout != nil && *selector.ScrollTimeout > 0 {
+		timeout = time.Duration(*selector.ScrollTimeout) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+	count := countElements(ctx, selector)
+	for scroll := 0; scroll < maxScrolls && time.Now().Before(deadline); scroll++ {
+		var height int
+		err := chromedp.Run(ctx,
+			chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight), document.body.scrollHeight`, &height),
+			chromedp.Sleep(pause),
+		)
+		if err != nil {
+			logErrors(err)
+			break
+		}
+		next := countElements(ctx, selector)
+		if next <= count {
+			break
+		}
+		count = next
+	}
+	return nil
+}
+
+func selectorElementScroll(job *workerJob, selector *selectors) ([]interface{}, error) {
+	action := job.pageAction(selector)
+	if action.err != nil {
+		return nil, action.err
+	}
+	return selectorElement(action.doc.Selection, selector, job), nil
+}
+
+func runPageAction(ctx context.Context, selector *selectors) pageAction {
+	var action pageAction
+	switch selector.Type {
+	case "SelectorElementClick":
+		action.err = clickElements(ctx, selector)
+	case "SelectorElementScroll":
+		action.err = scrollElements(ctx, selector)
+	case "SelectorScreenshot":
+		action.err = captureScreenshot(ctx, selector, &action.png, &action.pdf)
+		return action
+	}
+	if action.err == nil {
+		action.doc, action.err = tabDocument(ctx)
+	}
+	return action
+}
+
+// Page actions share the tab the page was rendered in and run in sitemap order,
+// so each one sees whatever the actions before it clicked open or scrolled in.
+func runPageActions(ctx context.Context, actions []*selectors) map[string]pageAction {
+	results := make(map[string]pageAction)
+	for _, selector := range actions {
+		results[selector.ID] = runPageAction(ctx, selector)
+	}
+	return results
+}
+
+func (job *workerJob) pageAction(selector *selectors) pageAction {
+	if action, ok := job.meta.Actions[selector.ID]; ok {
+		return action
+	}
+	// Without JavaScript there is no rendered tab to reuse, so the page is loaded
+	// again in a browser; the run report counts these reloads.
+	tab, err := crawlBrowsers.acquire(job.userAgent)
+	if err != nil {
+		return pageAction{err: err}
+	}
+	defer crawlBrowsers.release(tab)
+	crawlReport.reload(job.startURL)
+	err = openSelectorPage(tab.ctx, job.startURL, job.userAgent, selector)
+	if err != nil {
+		return pageAction{err: err}
+	}
+	return runPageAction(tab.ctx, selector)
+}
+
+func loginDefaults(account *login) login {
+	form := *account
+	if form.FormSelector == "" {
+		form.FormSelector = "form"
+	}
+	if form.UsernameField == "" {
+		form.UsernameField = "username"
+	}
+	if form.PasswordField == "" {
+		form.PasswordField = "password"
+	}
+	if form.SubmitSelector == "" {
+		form.SubmitSelector = `[type="submit"]`
+	}
+	if form.Wait == nil || form.Wait.Type == "" {
+		form.Wait = &waitCondition{Type: "networkIdle"}
+	}
+	return form
+}
+
+func loginHTTP(account login, userAgent string) error {
+	netClient := httpClient()
+	req, err := http.NewRequest(http.MethodGet, account.URL, nil)
+	if err != nil {
+		return err
+	}
+	if len(userAgent) > 0 {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	response, err := netClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		_ = response.Body.Close()
+		return &httpStatusError{URL: account.URL, StatusCode: response.StatusCode}
+	}
+	doc, err := goquery.NewDocumentFromReader(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return err
+	}
+	form := doc.Find(account.FormSelector).First()
+	if form.Length() == 0 {
+		return fmt.Errorf("login form %q not found on %s", account.FormSelector, account.URL)
+	}
+	values := url.Values{}
+	form.Find("input[name]").Each(func(_ int, input *goquery.Selection) {
+		name, _ := input.Attr("name")
+		inputType := strings.ToLower(input.AttrOr("type", "text"))
+		if inputType == "submit" || inputType == "button" || inputType == "image" {
+			return
+		}
+		if (inputType == "checkbox" || inputType == "radio") && !input.Is("[checked]") {
+			return
+		}
+		values.Set(name, input.AttrOr("value", ""))
+	})
+	values.Set(account.UsernameField, account.Username)
+	values.Set(account.PasswordField, account.Password)
+	action, err := toFixedURL(form.AttrOr("action", ""), account.URL)
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(form.AttrOr("method", http.MethodPost))
+	if method == http.MethodGet {
+		req, err = http.NewRequest(http.MethodGet, action+"?"+values.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(http.MethodPost, action, strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(userAgent) > 0 {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	response, err = netClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("login to %s failed: code %d", action, response.StatusCode)
+	}
+	return nil
+}
+
+func loginBrowser(account login, userAgent string) error {
+	ctx, cancel := newBrowserContext(userAgent)
+	defer cancel()
+	var cookies []*network.Cookie
+	err := chromedp.Run(ctx,
+		enableLifecycleEvents(),
+		chromedp.Navigate(account.URL),
+		chromedp.WaitVisible(account.FormSelector, chromedp.ByQuery),
+		chromedp.SetValue(account.FormSelector+` [name="`+account.UsernameField+`"]`, account.Username, chromedp.ByQuery),
+		chromedp.SetValue(account.FormSelector+` [name="`+account.PasswordField+`"]`, account.Password, chromedp.ByQuery),
+	)
+	if err != nil {
+		return err
+	}
+	idle, stopWatching := watchNetworkIdle(ctx)
+	defer stopWatching()
+	err = chromedp.Run(ctx,
+		chromedp.Click(account.FormSelector+" "+account.SubmitSelector, chromedp.ByQuery),
+		waitActions([]*waitCondition{account.Wait}, idle),
+		chromedp.WaitVisible(`body`, chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			cookies, err = network.GetAllCookies().Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	for _, cookie := range cookies {
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		cookieURL := &url.URL{Scheme: scheme, Host: strings.TrimPrefix(cookie.Domain, "."), Path: "/"}
+		sessionJar.SetCookies(cookieURL, []*http.Cookie{{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+		}})
+	}
+	return nil
+}
+
+func loginSession() error {
+	sessionJar, _ = cookiejar.New(nil)
+	resetHTTPClients()
+	if sitemap.Login == nil || sitemap.Login.URL == "" {
+		return nil
+	}
+	userAgent := ""
+	if len(settings.UserAgents) > 0 {
+		userAgent = settings.UserAgents[0]
+	}
+	account := loginDefaults(sitemap.Login)
+	if *settings.JavaScript {
+		return loginBrowser(account, userAgent)
+	}
+	return loginHTTP(account, userAgent)
 }
 
 func getURL(urls []string) <-chan string {
@@ -700,6 +1581,122 @@ func getURL(urls []string) <-chan string {
 	return c
 }
 
+func (job *workerJob) evaluateSelectors(scope *goquery.Selection, parent string) map[string]interface{} {
+	linkOutput := make(map[string]interface{})
+	for _, selector := range job.siteMap.Selectors {
+		if hasParent(&selector, parent) {
+			if selector.Type == "SelectorText" {
+				resultText := selectorText(scope, &selector)
+				if len(resultText) != 0 {
+					if len(resultText) == 1 {
+						linkOutput[selector.ID] = resultText[0]
+					} else {
+						linkOutput[selector.ID] = resultText
+					}
+				}
+			} else if selector.Type == "SelectorLink" {
+				links, err := selectorLink(scope, &selector, job.startURL)
+				if err != nil {
+					job.fail("extract", selector.ID, err)
+				}
+				if hasParent(&selector, selector.ID) {
+					for _, link := range crawlScope.filter(links, job.depth+1) {
+						job.frontier.push(link, job.depth+1)
+					}
+				} else {
+					childSelector := getChildSelector(&selector)
+					if childSelector == true {
+						linkOutput[selector.ID] = links
+					} else {
+						newSiteMap := getSiteMap(crawlScope.filter(links, job.depth+1), &selector)
+						result := scraper(newSiteMap, selector.ID, job.depth+1)
+						linkOutput[selector.ID] = result
+					}
+				}
+			} else if selector.Type == "SelectorSitemapXML" {
+				links, err := selectorSitemapXML(&selector)
+				if err != nil {
+					job.fail("sitemap", selector.ID, err)
+				}
+				childSelector := getChildSelector(&selector)
+				if childSelector == true {
+					linkOutput[selector.ID] = links
+				} else {
+					newSiteMap := getSiteMap(crawlScope.filter(links, job.depth+1), &selector)
+					result := scraper(newSiteMap, selector.ID, job.depth+1)
+					linkOutput[selector.ID] = result
+				}
+			} else if selector.Type == "SelectorElementAttribute" {
+				resultText := selectorElementAttribute(scope, &selector)
+				linkOutput[selector.ID] = resultText
+			} else if selector.Type == "SelectorImage" {
+				resultText, err := selectorImage(scope, &selector, job.startURL)
+				if err != nil {
+					job.fail("download", selector.ID, err)
+				}
+				if len(resultText) != 0 {
+					if len(resultText) == 1 {
+						linkOutput[selector.ID] = resultText[0]
+					} else {
+						linkOutput[selector.ID] = resultText
+					}
+				}
+			} else if selector.Type == "SelectorFile" {
+				resultText, err := selectorFile(scope, &selector, job.startURL)
+				if err != nil {
+					job.fail("download", selector.ID, err)
+				}
+				if len(resultText) != 0 {
+					if len(resultText) == 1 {
+						linkOutput[selector.ID] = resultText[0]
+					} else {
+						linkOutput[selector.ID] = resultText
+					}
+				}
+			} else if selector.Type == "SelectorElement" {
+				resultText := selectorElement(scope, &selector, job)
+				linkOutput[selector.ID] = resultText
+			} else if selector.Type == "SelectorElementClick" {
+				resultText, err := selectorElementClick(job, &selector)
+				if err != nil {
+					job.fail("click", selector.ID, err)
+				}
+				linkOutput[selector.ID] = resultText
+			} else if selector.Type == "SelectorNetworkResponse" {
+				resultText, err := selectorNetworkResponse(job.meta.Responses, &selector)
+				if err != nil {
+					job.fail("extract", selector.ID, err)
+				}
+				if len(resultText) != 0 {
+					if len(resultText) == 1 {
+						linkOutput[selector.ID] = resultText[0]
+					} else {
+						linkOutput[selector.ID] = resultText
+					}
+				}
+			} else if selector.Type == "SelectorElementScroll" {
+				resultText, err := selectorElementScroll(job, &selector)
+				if err != nil {
+					job.fail("scroll", selector.ID, err)
+				}
+				linkOutput[selector.ID] = resultText
+			} else if selector.Type == "SelectorScreenshot" {
+				resultText, err := selectorScreenshot(job, &selector)
+				if err != nil {
+					job.fail("screenshot", selector.ID, err)
+				}
+				if resultText != nil {
+					linkOutput[selector.ID] = resultText
+				}
+			} else if selector.Type == "SelectorTable" {
+				resultText := selectorTable(scope, &selector)
+				linkOutput[selector.ID] = resultText
+			}
+		}
+	}
+	return linkOutput
+}
+
 func worker(jobs <-chan workerJob, results chan<- workerJob, wg *sync.WaitGroup) {
 	defer wg.Done()
 	userAgents := settings.UserAgents
@@ -710,87 +1707,66 @@ func worker(jobs <-chan workerJob, results chan<- workerJob, wg *sync.WaitGroup)
 		userAgent := userAgents[count]
 		for job := range jobs {
 			var doc *goquery.Document
-			if *settings.RateLimit != 0 {
-				if time.Now().Sub(startTime).Seconds() < 60 && rate >= *settings.RateLimit {
-					time.Sleep(time.Now().Sub(startTime))
-				}
-				if time.Now().Sub(startTime).Seconds() >= 60 {
-					startTime = time.Now()
-					rate = 0
+			var meta pageMeta
+			allowed, _ := crawlRobots.check(job.startURL, userAgent)
+			if !allowed {
+				crawlReport.block(job.startURL)
+				if sitemap.Robots != "advisory" {
+					fmt.Println("Blocked by robots.txt:", job.startURL)
+					job.frontier.finish(job.startURL, frontierBlocked)
+					continue
 				}
 			}
-			rate++
+			delay := pageDelay(job.siteMap, job.parent)
+			if !*settings.JavaScript {
+				time.Sleep(delay)
+			}
+			var err error
 			if *settings.JavaScript {
 				if settings.Captcha != "" {
-					doc = navigateURL(job.startURL, userAgent)
+					doc, meta, err = navigateURL(job.startURL, userAgent, pageRenderOptions(job.siteMap, job.parent))
 				} else {
-					doc = emulateURL(job.startURL, userAgent)
+					doc, meta, err = emulateURL(job.startURL, userAgent, pageRenderOptions(job.siteMap, job.parent))
 				}
 			} else {
-				doc = crawlURL(job.startURL, userAgent)
+				doc, meta, err = crawlURL(job.startURL, userAgent)
+			}
+			if err != nil {
+				fmt.Println("Failed:", job.startURL, err)
+				job.fail("fetch", "", err)
 			}
 			if doc == nil {
+				job.state = frontierFailed
+				results <- job
 				continue
 			}
-			fmt.Println("URL:", job.startURL)
-			linkOutput := make(map[string]interface{})
-			for _, selector := range job.siteMap.Selectors {
-				if len(selector.ParentSelectors) > 0 && job.parent == selector.ParentSelectors[0] {
-					if selector.Type == "SelectorText" {
-						resultText := selectorText(doc, &selector)
-						if len(resultText) != 0 {
-							if len(resultText) == 1 {
-								linkOutput[selector.ID] = resultText[0]
-							} else {
-								linkOutput[selector.ID] = resultText
-							}
-						}
-					} else if selector.Type == "SelectorLink" {
-						links := selectorLink(doc, &selector, job.startURL)
-						if hasElement(selector.ParentSelectors, selector.ID) {
-							for _, link := range links {
-								if !hasElement(job.siteMap.StartURL, link) {
-									job.siteMap.StartURL = append(job.siteMap.StartURL, link)
-								}
-							}
-						} else {
-							childSelector := getChildSelector(&selector)
-							if childSelector == true {
-								linkOutput[selector.ID] = links
-							} else {
-								newSiteMap := getSiteMap(links, &selector)
-								result := scraper(newSiteMap, selector.ID)
-								linkOutput[selector.ID] = result
-							}
-						}
-					} else if selector.Type == "SelectorElementAttribute" {
-						resultText := selectorElementAttribute(doc, &selector)
-						linkOutput[selector.ID] = resultText
-					} else if selector.Type == "SelectorImage" {
-						resultText := selectorImage(doc, &selector)
-						if len(resultText) != 0 {
-							if len(resultText) == 1 {
-								linkOutput[selector.ID] = resultText[0]
-							} else {
-								linkOutput[selector.ID] = resultText
-							}
-						}
-					} else if selector.Type == "SelectorElement" {
-						resultText := selectorElement(doc, &selector)
-						linkOutput[selector.ID] = resultText
-					} else if selector.Type == "SelectorTable" {
-						resultText := selectorTable(doc, &selector)
-						linkOutput[selector.ID] = resultText
+			if *settings.CanonicalLinks {
+				href, ok := doc.Find(`link[rel="canonical"]`).Attr("href")
+				if ok {
+					canonical, err := toFixedURL(href, job.startURL)
+					if err != nil {
+						job.fail("canonical", "", err)
+					} else if canonicalURL(canonical) != canonicalURL(job.startURL) && !crawlVisited.add(canonical) {
+						crawlReport.duplicate(job.startURL)
+						job.state = frontierDone
+						results <- job
+						continue
 					}
 				}
 			}
+			fmt.Println("URL:", job.startURL)
+			crawlReport.page()
+			job.userAgent, job.meta = userAgent, meta
+			linkOutput := job.evaluateSelectors(doc.Selection, job.parent)
+			crawlReport.meta(job.startURL, meta)
 			job.linkOutput = linkOutput
+			job.state = frontierDone
 			results <- job
 		}
 	}
 }
 
-func scraper(siteMap *scraping, parent string) map[string]interface{} {
+func scraper(siteMap *scraping, parent string, depth int) map[string]interface{} {
 	output := make(map[string]interface{})
 	var wg sync.WaitGroup
 	jobs := make(chan workerJob, settings.Workers)
@@ -800,81 +1776,53 @@ func scraper(siteMap *scraping, parent string) map[string]interface{} {
 		wg.Add(1)
 		go worker(jobs, results, &wg)
 	}
+	// Nested link queues stay in memory: their results are part of the root page's
+	// record, which is only written once that page finishes, so resuming re-crawls
+	// the nested pages of every unfinished root page.
+	queue := newFrontier()
+	if parent == "_root" && crawlFrontier != nil {
+		queue = crawlFrontier
+	}
+	for startURL := range getURL(siteMap.StartURL) {
+		if validURL(startURL) {
+			queue.push(startURL, depth)
+		}
+	}
 	go func() {
-		fc := getURL(siteMap.StartURL)
-		if fc != nil {
-			for startURL := range fc {
-				if !validURL(startURL) {
-					continue
-				}
-				workerJob := workerJob{
-					parent:   parent,
-					startURL: startURL,
-					siteMap:  siteMap,
-				}
-				jobs <- workerJob
+		for {
+			startURL, depth, ok := queue.next()
+			if !ok {
+				break
 			}
-			close(jobs)
+			workerJob := workerJob{
+				parent:   parent,
+				depth:    depth,
+				startURL: startURL,
+				siteMap:  siteMap,
+				frontier: queue,
+			}
+			jobs <- workerJob
 		}
+		close(jobs)
 	}()
 	go func() {
 		pageOutput := make(map[string]interface{})
 		for job := range results {
 			if len(job.linkOutput) != 0 {
 				if job.parent == "_root" {
-					out, err := ioutil.ReadFile(settings.OutputFile)
+					err := writeOutput(job.startURL, job.linkOutput)
 					if err != nil {
-						logErrors(err)
-						os.Exit(1)
-					}
-					var data = map[string]interface{}{}
-					err = json.Unmarshal(out, &data)
-					data[job.startURL] = job.linkOutput
-					switch settings.OutputFile[strings.LastIndex(settings.OutputFile, ".")+1:] {
-					case "xml":
-						output, err := xml.MarshalIndent(websiteData(job.linkOutput), "", "  ")
-						if err != nil {
-							logErrors(err)
-							os.Exit(1)
-						}
-						_ = ioutil.WriteFile(settings.OutputFile, output, 0644)
-					case "csv":
-						csvFile, err := os.OpenFile(settings.OutputFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-						if err != nil {
-							logErrors(err)
-							os.Exit(1)
-						}
-						csvWriter := csv.NewWriter(csvFile)
-						var rows [][]string
-						for i, v := range data[job.startURL].(map[string]interface{}) {
-							rows = append(rows, []string{i, fmt.Sprint(v)})
-						}
-						for _, row := range rows {
-							err = csvWriter.Write(row)
-							if err != nil {
-								frontendLog(err)
-								break
-							}
-						}
-						csvWriter.Flush()
-						err = csvFile.Close()
-						if err != nil {
-							frontendLog(err)
-						}
-					case "json":
-						output, err := json.MarshalIndent(data, "", " ")
-						if err != nil {
-							logErrors(err)
-							os.Exit(1)
-						}
-						_ = ioutil.WriteFile(settings.OutputFile, output, 0644)
-					default:
-						fmt.Println("Error: Please choose an output format.")
+						job.fail("output", "", err)
 					}
 				} else {
 					pageOutput[job.startURL] = job.linkOutput
 				}
 			}
+			if job.state == frontierFailed {
+				crawlReport.fail()
+			}
+			crawlReport.errors(job.errors)
+			job.frontier.finish(job.startURL, job.state)
 		}
 		outputChannel <- pageOutput
 	}()
@@ -884,12 +1832,98 @@ func scraper(siteMap *scraping, parent string) map[string]interface{} {
 	return output
 }
 
+func readOutput() (map[string]interface{}, error) {
+	out, err := ioutil.ReadFile(settings.OutputFile)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return data, nil
+	}
+	err = json.Unmarshal(out, &data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: can't parse existing output: %v", settings.OutputFile, err)
+	}
+	return data, nil
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+func writeOutput(startURL string, linkOutput map[string]interface{}) error {
+	switch settings.OutputFile[strings.LastIndex(settings.OutputFile, ".")+1:] {
+	case "xml":
+		output, err := xml.MarshalIndent(websiteData(linkOutput), "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(settings.OutputFile, output)
+	case "csv":
+		csvFile, err := os.OpenFile(settings.OutputFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		csvWriter := csv.NewWriter(csvFile)
+		for i, v := range linkOutput {
+			err = csvWriter.Write([]string{i, fmt.Sprint(v)})
+			if err != nil {
+				break
+			}
+		}
+		csvWriter.Flush()
+		if err == nil {
+			err = csvWriter.Error()
+		}
+		closeErr := csvFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	case "json":
+		data, err := readOutput()
+		if err != nil {
+			return err
+		}
+		data[startURL] = linkOutput
+		output, err := json.MarshalIndent(data, "", " ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(settings.OutputFile, output)
+	default:
+		return fmt.Errorf("unsupported output format: %s", settings.OutputFile)
+	}
+}
+
 func validURL(uri string) bool {
 	_, err := url.ParseRequestURI(uri)
 	return err == nil
 }
 
-func outputResult() {
+func outputResult() error {
 	userFormat := strings.ToLower(settings.OutputFile[strings.LastIndex(settings.OutputFile, ".")+1:])
 	allowedFormat := map[string]bool{
 		"csv":  true,
@@ -897,22 +1931,76 @@ func outputResult() {
 		"json": true,
 	}
 	if allowedFormat[userFormat] {
-		err := ioutil.WriteFile(settings.OutputFile, []byte{}, 0644)
-		if err != nil {
-			logErrors(err)
+		if shouldResume {
+			if _, err := os.Stat(settings.OutputFile); err == nil {
+				if userFormat == "json" {
+					_, err = readOutput()
+				}
+				return err
+			}
 		}
-	} else {
-		_, _ = fmt.Fprintf(os.Stderr, "Format \"%s\" not supported", userFormat)
-		os.Exit(1)
+		return ioutil.WriteFile(settings.OutputFile, []byte{}, 0644)
 	}
+	return fmt.Errorf("format \"%s\" not supported", userFormat)
 }
 
 func scrape() {
 	readJSON()
 	clearCache()
 	siteMap := sitemap
-	outputResult()
-	startTime = time.Now()
-	rate = 0
-	_ = scraper(&siteMap, "_root")
+	err := validateParents(sitemap)
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Invalid sitemap:", err)
+		return
+	}
+	err = validateSelectors(sitemap)
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Invalid selector:", err)
+		return
+	}
+	err = outputResult()
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		return
+	}
+	crawlLimiter = settingsLimiter()
+	crawlVisited.reset()
+	crawlRobots.reset()
+	crawlReport = &runReport{}
+	crawlBrowsers = settingsBrowserPool()
+	defer crawlBrowsers.close()
+	crawlScope, err = newScope(sitemap)
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Invalid crawl scope:", err)
+		return
+	}
+	err = loginSession()
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Login failed:", err)
+		return
+	}
+	crawlFrontier, err = openFrontier(frontierPath(sitemap.ID), shouldResume)
+	if err != nil {
+		logErrors(err)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Can't open crawl frontier:", err)
+		return
+	}
+	_ = scraper(&siteMap, "_root", 0)
+	crawlFrontier.close()
+	closeAssetStore()
+	err = writeRunReport()
+	if err != nil {
+		logErrors(err)
+	}
+	fmt.Println("Pages scraped:", crawlReport.Pages)
+	fmt.Println("Blocked by robots.txt:", crawlReport.BlockedCount)
+	fmt.Println("Duplicates:", crawlReport.DuplicateCount)
+	fmt.Println("Browser reloads:", crawlReport.ReloadCount)
+	fmt.Println("Failed:", crawlReport.FailedCount)
+	fmt.Println("Errors:", crawlReport.ErrorCount)
 }
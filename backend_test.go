@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestTableGrid(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want [][]string
+	}{
+		{
+			name: "plain",
+			html: `<tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr>`,
+			want: [][]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			name: "colspan",
+			html: `<tr><td colspan="2">a</td><td>b</td></tr>`,
+			want: [][]string{{"a", "a", "b"}},
+		},
+		{
+			name: "rowspan",
+			html: `<tr><td rowspan="2">a</td><td>b</td></tr><tr><td>c</td></tr>`,
+			want: [][]string{{"a", "b"}, {"a", "c"}},
+		},
+		{
+			name: "trailing rowspan",
+			html: `<tr><td>a</td><td rowspan="3">b</td></tr><tr><td>c</td></tr><tr><td>d</td></tr>`,
+			want: [][]string{{"a", "b"}, {"c", "b"}, {"d", "b"}},
+		},
+		{
+			name: "rowspan and colspan",
+			html: `<tr><td rowspan="2" colspan="2">a</td><td>b</td></tr><tr><td>c</td></tr>`,
+			want: [][]string{{"a", "a", "b"}, {"a", "a", "c"}},
+		},
+		{
+			name: "invalid spans",
+			html: `<tr><td colspan="x" rowspan="0">a</td></tr><tr><td>b</td></tr>`,
+			want: [][]string{{"a"}, {"b"}},
+		},
+	}
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table>` + test.html + `</table>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tableGrid(doc.Find("tr"))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: tableGrid() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSelectorTable(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`
+		<table><thead><tr><th>name</th><th>price</th></tr></thead><tbody><tr><td>a</td><td>1</td></tr></tbody></table>
+		<table><tr><th>name</th><th>price</th></tr><tr><td>b</td><td>2</td></tr></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := selectorTable(doc.Selection, &selectors{ID: "t", Selector: "table", Multiple: newBool(false)})
+	want := []interface{}{
+		map[string]interface{}{"name": "a", "price": "1"},
+		map[string]interface{}{"name": "b", "price": "2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectorTable() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateParents(t *testing.T) {
+	tests := []struct {
+		name      string
+		selectors []selectors
+		valid     bool
+	}{
+		{"single root", []selectors{
+			{ID: "title", Type: "SelectorText", ParentSelectors: []string{"_root"}},
+		}, true},
+		{"shared child", []selectors{
+			{ID: "list", Type: "SelectorLink", ParentSelectors: []string{"_root"}},
+			{ID: "price", Type: "SelectorText", ParentSelectors: []string{"_root", "list"}},
+		}, true},
+		{"link pagination", []selectors{
+			{ID: "next", Type: "SelectorLink", ParentSelectors: []string{"_root", "next"}},
+		}, true},
+		{"no parents", []selectors{
+			{ID: "title", Type: "SelectorText"},
+		}, false},
+		{"blank parent", []selectors{
+			{ID: "title", Type: "SelectorText", ParentSelectors: []string{""}},
+		}, false},
+		{"unknown parent", []selectors{
+			{ID: "title", Type: "SelectorText", ParentSelectors: []string{"_root", "missing"}},
+		}, false},
+		{"nothing under root", []selectors{
+			{ID: "a", Type: "SelectorLink", ParentSelectors: []string{"b"}},
+			{ID: "b", Type: "SelectorLink", ParentSelectors: []string{"a"}},
+		}, false},
+		{"element self parent", []selectors{
+			{ID: "item", Type: "SelectorElement", ParentSelectors: []string{"_root", "item"}},
+		}, false},
+		{"click self parent", []selectors{
+			{ID: "more", Type: "SelectorElementClick", ParentSelectors: []string{"_root", "more"}},
+		}, false},
+		{"cycle through a link", []selectors{
+			{ID: "a", Type: "SelectorElement", ParentSelectors: []string{"_root", "c"}},
+			{ID: "b", Type: "SelectorElement", ParentSelectors: []string{"a"}},
+			{ID: "c", Type: "SelectorLink", ParentSelectors: []string{"b"}},
+		}, true},
+		{"cycle within a page", []selectors{
+			{ID: "a", Type: "SelectorElement", ParentSelectors: []string{"_root", "c"}},
+			{ID: "b", Type: "SelectorElement", ParentSelectors: []string{"a"}},
+			{ID: "c", Type: "SelectorElement", ParentSelectors: []string{"b"}},
+		}, false},
+		{"link and sitemap cycle", []selectors{
+			{ID: "sitemap", Type: "SelectorSitemapXML", ParentSelectors: []string{"_root", "category"}},
+			{ID: "category", Type: "SelectorLink", ParentSelectors: []string{"sitemap"}},
+		}, true},
+		{"screenshot under link", []selectors{
+			{ID: "detail", Type: "SelectorLink", ParentSelectors: []string{"_root"}},
+			{ID: "shot", Type: "SelectorScreenshot", ParentSelectors: []string{"detail"}},
+		}, true},
+		{"network response under click", []selectors{
+			{ID: "more", Type: "SelectorElementClick", ParentSelectors: []string{"_root"}},
+			{ID: "api", Type: "SelectorNetworkResponse", ParentSelectors: []string{"more"}},
+		}, false},
+		{"network response under root", []selectors{
+			{ID: "api", Type: "SelectorNetworkResponse", ParentSelectors: []string{"_root"}},
+		}, true},
+		{"scroll under element", []selectors{
+			{ID: "item", Type: "SelectorElement", ParentSelectors: []string{"_root"}},
+			{ID: "feed", Type: "SelectorElementScroll", ParentSelectors: []string{"item"}},
+		}, false},
+	}
+	for _, test := range tests {
+		err := validateParents(scraping{Selectors: test.selectors})
+		if (err == nil) != test.valid {
+			t.Errorf("%s: validateParents() error = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func sitemapServer(t *testing.T) *httptest.Server {
+	var pages bytes.Buffer
+	writer := gzip.NewWriter(&pages)
+	_, _ = writer.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>/a</loc><priority>0.9</priority></url>
+	<url><loc>/b</loc><priority>0.2</priority></url>
+	<url><loc> c </loc></url>
+</urlset>`))
+	_ = writer.Close()
+	files := map[string][]byte{
+		"/sitemap.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>/pages.xml.gz</loc></sitemap>
+	<sitemap><loc>/sitemap.xml</loc></sitemap>
+	<sitemap><loc>posts.xml</loc></sitemap>
+</sitemapindex>`),
+		"/pages.xml.gz": pages.Bytes(),
+		"/posts.xml":    []byte(`<urlset><url><loc>/post/1</loc><priority>0.8</priority></url></urlset>`),
+		"/broken.xml":   []byte(`<sitemapindex><sitemap><loc>/posts.xml</loc></sitemap><sitemap><loc>/missing.xml</loc></sitemap></sitemapindex>`),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchSitemapXML(t *testing.T) {
+	server := sitemapServer(t)
+	entries, err := fetchSitemapXML(server.URL+"/sitemap.xml", make(map[string]bool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, entry := range entries {
+		locs = append(locs, strings.TrimSpace(entry.Loc))
+	}
+	want := []string{"/a", "/b", "c", "/post/1"}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("fetchSitemapXML() locs = %q, want %q", locs, want)
+	}
+	entries, err = fetchSitemapXML(server.URL+"/broken.xml", make(map[string]bool))
+	if statusCode(err) != http.StatusNotFound {
+		t.Errorf("fetchSitemapXML() with a missing child error = %v, want code 404", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("fetchSitemapXML() with a missing child kept %d entries, want 1", len(entries))
+	}
+}
+
+func TestSelectorSitemapXML(t *testing.T) {
+	server := sitemapServer(t)
+	tests := []struct {
+		name     string
+		regex    string
+		priority float64
+		want     []string
+	}{
+		{"all", "", 0, []string{"/a", "/b", "/c", "/post/1"}},
+		{"minimum priority", "", 0.5, []string{"/a", "/c", "/post/1"}},
+		{"found url regex", "post", 0, []string{"/post/1"}},
+	}
+	for _, test := range tests {
+		selector := &selectors{
+			ID:              "sitemap",
+			SitemapURLs:     []string{server.URL + "/sitemap.xml"},
+			FoundUrlRegex:   test.regex,
+			MinimumPriority: newFloat64(test.priority),
+		}
+		links, err := selectorSitemapXML(selector)
+		if err != nil {
+			t.Errorf("%s: selectorSitemapXML() error = %v", test.name, err)
+			continue
+		}
+		var want []string
+		for _, path := range test.want {
+			want = append(want, server.URL+path)
+		}
+		if !reflect.DeepEqual(links, want) {
+			t.Errorf("%s: selectorSitemapXML() = %q, want %q", test.name, links, want)
+		}
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	saved := settings
+	defer func() { settings = saved }()
+	settings.OutputFile = filepath.Join(t.TempDir(), "output.json")
+	err := ioutil.WriteFile(settings.OutputFile, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"http://example.com/a", "http://example.com/b"} {
+		err = writeOutput(page, map[string]interface{}{"title": page})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := readOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Errorf("output has %d records, want 2: %v", len(data), data)
+	}
+	truncated := []byte(`{"http://example.com/a": {"title"`)
+	err = ioutil.WriteFile(settings.OutputFile, truncated, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeOutput("http://example.com/c", map[string]interface{}{"title": "c"})
+	if err == nil {
+		t.Error("writeOutput() over a truncated output succeeded")
+	}
+	out, _ := ioutil.ReadFile(settings.OutputFile)
+	if !bytes.Equal(out, truncated) {
+		t.Errorf("writeOutput() replaced an output it couldn't parse: %s", out)
+	}
+	leftovers, _ := filepath.Glob(settings.OutputFile + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestEvaluateSelectorsNesting(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<h1>Shop</h1>
+		<div class="product"><h2>A</h2><ul class="tags"><li>x</li><li>y</li></ul></div>
+		<div class="product"><h2>B</h2></div>
+		<div class="product"><p>no matching children</p></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(id, query string, multiple bool, parents ...string) selectors {
+		return selectors{ID: id, Type: "SelectorText", Selector: query, Multiple: newBool(multiple), ParentSelectors: parents}
+	}
+	element := func(id, query string, multiple bool, parents ...string) selectors {
+		return selectors{ID: id, Type: "SelectorElement", Selector: query, Multiple: newBool(multiple), ParentSelectors: parents}
+	}
+	job := &workerJob{startURL: "http://example.com/", siteMap: &scraping{Selectors: []selectors{
+		text("title", "h1", false, "_root"),
+		element("products", "div.product", true, "_root"),
+		text("name", "h2", false, "products", "_root"),
+		element("tags", "ul.tags", false, "products"),
+		text("tag", "li", true, "tags"),
+	}}}
+	got := job.evaluateSelectors(doc.Selection, "_root")
+	want := map[string]interface{}{
+		"title": "Shop",
+		"name":  "A",
+		"products": []interface{}{
+			map[string]interface{}{
+				"name": "A",
+				"tags": []interface{}{map[string]interface{}{"tag": []string{"x", "y"}}},
+			},
+			map[string]interface{}{"name": "B", "tags": []interface{}(nil)},
+			map[string]interface{}{"tags": []interface{}(nil)},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("evaluateSelectors() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"strings"
+)
+
+var (
+	defaultBlockResourceTypes = []string{"Image", "Font", "Media"}
+	defaultBlockURLPatterns   = []string{
+		"*google-analytics.com*",
+		"*googletagmanager.com*",
+		"*googlesyndication.com*",
+		"*doubleclick.net*",
+		"*adservice.google.*",
+		"*facebook.net*",
+		"*hotjar.com*",
+		"*scorecardresearch.com*",
+	}
+	resourceTypes = []network.ResourceType{
+		network.ResourceTypeDocument,
+		network.ResourceTypeStylesheet,
+		network.ResourceTypeImage,
+		network.ResourceTypeMedia,
+		network.ResourceTypeFont,
+		network.ResourceTypeScript,
+		network.ResourceTypeTextTrack,
+		network.ResourceTypeXHR,
+		network.ResourceTypeFetch,
+		network.ResourceTypeEventSource,
+		network.ResourceTypeWebSocket,
+		network.ResourceTypeManifest,
+		network.ResourceTypeSignedExchange,
+		network.ResourceTypePing,
+		network.ResourceTypeCSPViolationReport,
+		network.ResourceTypeOther,
+	}
+)
+
+func resourceType(name string) (network.ResourceType, bool) {
+	for _, resource := range resourceTypes {
+		if strings.EqualFold(string(resource), strings.TrimSpace(name)) {
+			return resource, true
+		}
+	}
+	return "", false
+}
+
+func sitemapHasType(siteMap scraping, selectorTypes ...string) bool {
+	for _, selector := range siteMap.Selectors {
+		for _, selectorType := range selectorTypes {
+			if selector.Type == selectorType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func blockPatterns() []*fetch.RequestPattern {
+	var patterns []*fetch.RequestPattern
+	keepImages := sitemapHasType(sitemap, "SelectorImage", "SelectorScreenshot")
+	keepFonts := sitemapHasType(sitemap, "SelectorScreenshot")
+	for _, name := range settings.BlockResourceTypes {
+		resource, ok := resourceType(name)
+		if !ok || resource == network.ResourceTypeDocument {
+			continue
+		}
+		if (keepImages && resource == network.ResourceTypeImage) || (keepFonts && resource == network.ResourceTypeFont) {
+			continue
+		}
+		patterns = append(patterns, &fetch.RequestPattern{ResourceType: resource})
+	}
+	for _, pattern := range settings.BlockURLPatterns {
+		if strings.TrimSpace(pattern) != "" {
+			patterns = append(patterns, &fetch.RequestPattern{URLPattern: strings.TrimSpace(pattern)})
+		}
+	}
+	return patterns
+}
+
+func blockRequests(ctx context.Context, patterns []*fetch.RequestPattern) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			err := chromedp.Run(ctx, fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient))
+			if err != nil && ctx.Err() == nil {
+				logErrors(err)
+			}
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable().WithPatterns(patterns))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/chromedp"
+	"sync"
+)
+
+const (
+	defaultTabRecycle = 50
+)
+
+var (
+	crawlBrowsers = newBrowserPool(1, 1, defaultTabRecycle)
+)
+
+type browserInstance struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type browserTab struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	browser   *browserInstance
+	userAgent string
+	pages     int
+}
+
+type browserPool struct {
+	mu       sync.Mutex
+	slots    chan *browserTab
+	browsers []*browserInstance
+	next     int
+	recycle  int
+	block    []*fetch.RequestPattern
+	closed   bool
+}
+
+func newBrowserPool(size, instances, recycle int) *browserPool {
+	if size < 1 {
+		size = 1
+	}
+	if instances < 1 {
+		instances = 1
+	}
+	if instances > size {
+		instances = size
+	}
+	pool := &browserPool{
+		slots:    make(chan *browserTab, size),
+		browsers: make([]*browserInstance, instances),
+		recycle:  recycle,
+	}
+	for i := 0; i < size; i++ {
+		pool.slots <- nil
+	}
+	return pool
+}
+
+func browserInstances() int {
+	if settings.BrowserInstances != nil && *settings.BrowserInstances > 0 {
+		return *settings.BrowserInstances
+	}
+	return 1
+}
+
+func tabRecycle() int {
+	if settings.TabRecycle != nil && *settings.TabRecycle > 0 {
+		return *settings.TabRecycle
+	}
+	return defaultTabRecycle
+}
+
+func settingsBrowserPool() *browserPool {
+	pool := newBrowserPool(settings.Workers, browserInstances(), tabRecycle())
+	pool.block = blockPatterns()
+	return pool
+}
+
+func browserAllocatorOptions(proxy, userAgent string) []chromedp.ExecAllocatorOption {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	if proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+	if userAgent != "" {
+		opts = append(opts, chromedp.UserAgent(userAgent))
+	}
+	return opts
+}
+
+func startBrowser(proxy string) (*browserInstance, error) {
+	allocatorCtx, cancelAllocator := chromedp.NewExecAllocator(context.Background(), browserAllocatorOptions(proxy, "")...)
+	ctx, cancelContext := chromedp.NewContext(allocatorCtx)
+	browser := &browserInstance{ctx: ctx, cancel: func() {
+		cancelContext()
+		cancelAllocator()
+	}}
+	err := chromedp.Run(ctx)
+	if err != nil {
+		browser.cancel()
+		return nil, err
+	}
+	return browser, nil
+}
+
+func (pool *browserPool) browser() (*browserInstance, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.closed {
+		return nil, context.Canceled
+	}
+	index := pool.next % len(pool.browsers)
+	pool.next++
+	browser := pool.browsers[index]
+	if browser != nil && browser.ctx.Err() == nil {
+		return browser, nil
+	}
+	if browser != nil {
+		browser.cancel()
+	}
+	proxy := ""
+	if len(settings.Proxy) > 0 {
+		proxy = settings.Proxy[index%len(settings.Proxy)]
+	}
+	browser, err := startBrowser(proxy)
+	if err != nil {
+		return nil, err
+	}
+	pool.browsers[index] = browser
+	return browser, nil
+}
+
+func (pool *browserPool) acquire(userAgent string) (*browserTab, error) {
+	tab := <-pool.slots
+	if tab != nil && (tab.ctx.Err() != nil || tab.browser.ctx.Err() != nil || tab.pages >= pool.recycle || (userAgent == "" && tab.userAgent != "")) {
+		tab.cancel()
+		tab = nil
+	}
+	if tab == nil {
+		browser, err := pool.browser()
+		if err != nil {
+			pool.slots <- nil
+			return nil, err
+		}
+		ctx, cancel := chromedp.NewContext(browser.ctx)
+		tab = &browserTab{ctx: ctx, cancel: cancel, browser: browser}
+		err = blockRequests(tab.ctx, pool.block)
+		if err != nil {
+			tab.cancel()
+			pool.slots <- nil
+			return nil, err
+		}
+	}
+	if tab.userAgent != userAgent {
+		err := chromedp.Run(tab.ctx, emulation.SetUserAgentOverride(userAgent))
+		if err != nil {
+			tab.cancel()
+			pool.slots <- nil
+			return nil, err
+		}
+		tab.userAgent = userAgent
+	}
+	return tab, nil
+}
+
+func (pool *browserPool) release(tab *browserTab) {
+	tab.pages++
+	pool.slots <- tab
+}
+
+func (pool *browserPool) close() {
+	pool.mu.Lock()
+	pool.closed = true
+	pool.mu.Unlock()
+	for i := 0; i < cap(pool.slots); i++ {
+		tab := <-pool.slots
+		if tab != nil {
+			tab.cancel()
+		}
+	}
+	for _, browser := range pool.browsers {
+		if browser != nil {
+			browser.cancel()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	defaultStripParams = []string{"utm_*", "gclid", "fbclid", "msclkid", "mc_cid", "mc_eid"}
+	crawlVisited       = newVisitedSet()
+)
+
+type visitedSet struct {
+	mu   sync.Mutex
+	urls map[string]bool
+}
+
+func newVisitedSet() *visitedSet {
+	return &visitedSet{urls: make(map[string]bool)}
+}
+
+func (v *visitedSet) add(pageURL string) bool {
+	canonical := canonicalURL(pageURL)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.urls[canonical] {
+		return false
+	}
+	v.urls[canonical] = true
+	return true
+}
+
+func (v *visitedSet) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.urls = make(map[string]bool)
+}
+
+func stripParam(name string) bool {
+	for _, pattern := range settings.StripParams {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalURL(pageURL string) string {
+	uri, err := url.Parse(pageURL)
+	if err != nil || uri.Host == "" {
+		return pageURL
+	}
+	uri.Scheme = strings.ToLower(uri.Scheme)
+	uri.Host = strings.ToLower(uri.Host)
+	if (uri.Scheme == "http" && uri.Port() == "80") || (uri.Scheme == "https" && uri.Port() == "443") {
+		uri.Host = uri.Hostname()
+	}
+	if uri.Path == "" {
+		uri.Path = "/"
+	}
+	uri.Fragment = ""
+	uri.RawFragment = ""
+	query := uri.Query()
+	for name := range query {
+		if stripParam(name) {
+			query.Del(name)
+		}
+	}
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
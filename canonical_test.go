@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	settings.StripParams = defaultStripParams
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"HTTP://Example.COM", "http://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http://example.com/a#section", "http://example.com/a"},
+		{"http://example.com/a?utm_source=x&utm_medium=y&id=1", "http://example.com/a?id=1"},
+		{"http://example.com/a?gclid=1&fbclid=2", "http://example.com/a"},
+		{"http://example.com/a?b=2&a=1", "http://example.com/a?a=1&b=2"},
+		{"http://example.com/Path", "http://example.com/Path"},
+		{"/relative/path", "/relative/path"},
+	}
+	for _, test := range tests {
+		got := canonicalURL(test.in)
+		if got != test.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestVisitedSetAdd(t *testing.T) {
+	settings.StripParams = defaultStripParams
+	visited := newVisitedSet()
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"http://example.com/a", true},
+		{"HTTP://EXAMPLE.com/a#top", false},
+		{"http://example.com/a?utm_campaign=z", false},
+		{"http://example.com/b", true},
+	}
+	for _, test := range tests {
+		got := visited.add(test.in)
+		if got != test.want {
+			t.Errorf("add(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
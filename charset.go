@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+)
+
+const (
+	charsetSniffLength = 1024
+)
+
+var (
+	utf8BOM = []byte{0xef, 0xbb, 0xbf}
+)
+
+type pageMeta struct {
+	StatusCode  int                   `json:"statusCode,omitempty"`
+	ContentType string                `json:"contentType,omitempty"`
+	Charset     string                `json:"charset,omitempty"`
+	Responses   []networkResponse     `json:"-"`
+	Actions     map[string]pageAction `json:"-"`
+}
+
+func metaCharset(head []byte) bool {
+	tokenizer := html.NewTokenizer(bytes.NewReader(head))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "meta" {
+				continue
+			}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				if string(key) == "charset" || (string(key) == "content" && bytes.Contains(bytes.ToLower(value), []byte("charset="))) {
+					return true
+				}
+			}
+		}
+	}
+}
+
+func decodeBody(body io.Reader, contentType string) (io.Reader, string, error) {
+	buffered := bufio.NewReaderSize(body, charsetSniffLength)
+	head, err := buffered.Peek(charsetSniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+	encoding, name, certain := charset.DetermineEncoding(head, contentType)
+	if !certain && !metaCharset(head) {
+		encoding, name = unicode.UTF8, "utf-8"
+	}
+	if bytes.HasPrefix(head, utf8BOM) {
+		_, _ = buffered.Discard(len(utf8BOM))
+	}
+	return transform.NewReader(buffered, encoding.NewDecoder()), name, nil
+}
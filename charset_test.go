@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	padding := strings.Repeat("a", 1100)
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantCharset string
+		wantText    string
+	}{
+		{"undeclared utf-8 after sniff window", "<p>" + padding + "héllo</p>", "text/html", "utf-8", "héllo"},
+		{"utf-8 bom", "\xef\xbb\xbfhéllo", "text/html", "utf-8", "héllo"},
+		{"content-type charset", "h\xe9llo", "text/html; charset=ISO-8859-1", "windows-1252", "héllo"},
+		{"meta charset", `<meta charset="windows-1252">h` + "\xe9llo", "text/html", "windows-1252", "héllo"},
+		{"meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=shift_jis">` + "\x93\xfa\x96\x7b", "", "shift_jis", "日本"},
+	}
+	for _, test := range tests {
+		reader, name, err := decodeBody(strings.NewReader(test.body), test.contentType)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if name != test.wantCharset {
+			t.Errorf("%s: charset = %q, want %q", test.name, name, test.wantCharset)
+		}
+		if !strings.Contains(string(data), test.wantText) || strings.HasPrefix(string(data), "\ufeff") {
+			t.Errorf("%s: decoded = %q, want it to contain %q", test.name, data, test.wantText)
+		}
+	}
+}
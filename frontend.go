@@ -1,7 +1,7 @@
 package main
 
 import (
-	"crypto/tls"
+	"flag"
 	"fmt"
 	"github.com/zserge/lorca"
 	"io/ioutil"
@@ -10,10 +10,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	shouldScrape = false
+	shouldResume = false
 )
 
 func frontendLog(err error) {
@@ -29,6 +31,66 @@ func ifThenElse(condition bool, a string, b string) string {
 	return b
 }
 
+func splitLines(s string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+func uiWaitInputs(prefix string, condition *waitCondition) string {
+	wait := waitCondition{}
+	if condition != nil {
+		wait = *condition
+	}
+	value := wait.Selector
+	if wait.Type == "expression" {
+		value = wait.Expression
+	}
+	return `<select id="` + prefix + `_wait_type">
+					<option value="" ` + ifThenElse(wait.Type == "", `selected`, "") + `>No wait</option>
+					<option value="selector" ` + ifThenElse(wait.Type == "selector", `selected`, "") + `>CSS selector</option>
+					<option value="networkIdle" ` + ifThenElse(wait.Type == "networkIdle", `selected`, "") + `>Network idle</option>
+					<option value="expression" ` + ifThenElse(wait.Type == "expression", `selected`, "") + `>JS expression</option>
+					<option value="timeout" ` + ifThenElse(wait.Type == "timeout", `selected`, "") + `>Fixed timeout</option>
+				</select>
+				<input type="text" placeholder="Selector or expression" id="` + prefix + `_wait_value" value="` + value + `"></input>
+				<input type="number" placeholder="Timeout (ms)" id="` + prefix + `_wait_timeout" value="` + optionalInt(wait.Timeout) + `"></input>`
+}
+
+func readWait(ui lorca.UI, prefix string) *waitCondition {
+	wait := &waitCondition{Type: fmt.Sprint(ui.Eval(`document.getElementById("` + prefix + `_wait_type").value;`))}
+	if wait.Type == "" {
+		return nil
+	}
+	value := fmt.Sprint(ui.Eval(`document.getElementById("` + prefix + `_wait_value").value;`))
+	if wait.Type == "expression" {
+		wait.Expression = value
+	} else if wait.Type == "selector" {
+		wait.Selector = value
+	}
+	wait.Timeout = readOptionalInt(ui, prefix+"_wait_timeout")
+	return wait
+}
+
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func readOptionalInt(ui lorca.UI, id string) *int {
+	value, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("` + id + `").value;`)))
+	if err != nil || value <= 0 {
+		return nil
+	}
+	return newInt(value)
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -143,6 +205,44 @@ func saveSettings(ui lorca.UI) {
 	settings.Workers, err = strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_workers").value;`)))
 	intA, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_rate_limit").value;`)))
 	settings.RateLimit = newInt(intA)
+	rps, _ := strconv.ParseFloat(fmt.Sprint(ui.Eval(`document.getElementById("settings_rps").value;`)), 64)
+	settings.RequestsPerSecond = newFloat64(rps)
+	burst, _ := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_burst").value;`)))
+	settings.Burst = newInt(burst)
+	hostConcurrency, _ := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_host_concurrency").value;`)))
+	settings.HostConcurrency = newInt(hostConcurrency)
+	retries, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_retries").value;`)))
+	if err == nil {
+		settings.Retries = newInt(retries)
+	}
+	backoff, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_retry_backoff").value;`)))
+	if err == nil {
+		settings.RetryBackoff = newInt(backoff)
+	}
+	connectTimeout, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_connect_timeout").value;`)))
+	if err == nil {
+		settings.ConnectTimeout = newInt(connectTimeout)
+	}
+	readTimeout, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_read_timeout").value;`)))
+	if err == nil {
+		settings.ReadTimeout = newInt(readTimeout)
+	}
+	timeout, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_timeout").value;`)))
+	if err == nil {
+		settings.Timeout = newInt(timeout)
+	}
+	idleConns, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_idle_conns").value;`)))
+	if err == nil {
+		settings.MaxIdleConnsPerHost = newInt(idleConns)
+	}
+	browserInstances, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_browsers").value;`)))
+	if err == nil {
+		settings.BrowserInstances = newInt(browserInstances)
+	}
+	tabRecycle, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("settings_tab_recycle").value;`)))
+	if err == nil {
+		settings.TabRecycle = newInt(tabRecycle)
+	}
 	if err != nil {
 		frontendLog(err)
 	}
@@ -160,6 +260,21 @@ func saveSettings(ui lorca.UI) {
 		settings.Proxy = append(settings.Proxy, fmt.Sprint(ui.Eval(code)))
 	}
 	settings.OutputFile = fmt.Sprint(ui.Eval(`document.getElementById("settings_output").value;`))
+	settings.StripParams = []string{}
+	for _, param := range strings.Split(fmt.Sprint(ui.Eval(`document.getElementById("settings_strip_params").value;`)), ",") {
+		if strings.TrimSpace(param) != "" {
+			settings.StripParams = append(settings.StripParams, strings.TrimSpace(param))
+		}
+	}
+	settings.BlockResourceTypes = []string{}
+	for _, resource := range strings.Split(fmt.Sprint(ui.Eval(`document.getElementById("settings_block_types").value;`)), ",") {
+		if strings.TrimSpace(resource) != "" {
+			settings.BlockResourceTypes = append(settings.BlockResourceTypes, strings.TrimSpace(resource))
+		}
+	}
+	settings.BlockURLPatterns = splitLines(fmt.Sprint(ui.Eval(`document.getElementById("settings_block_urls").value;`)))
+	settings.CanonicalLinks = newBool(fmt.Sprint(ui.Eval(`document.getElementById("settings_canonical").checked.toString();`)) == "true")
+	settings.HTTP2 = newBool(fmt.Sprint(ui.Eval(`document.getElementById("settings_http2").checked.toString();`)) == "true")
 	writeJSON()
 	err = ui.Load("data:text/html," + url.PathEscape(uiViewSitemap()))
 	if err != nil {
@@ -234,7 +349,19 @@ func uiEditSettings() string {
 				</tr>
 				<tr><th>JavaScript</th><td><input id="settings_js" type="checkbox" ` + ifThenElse(*settings.JavaScript, `checked`, "") + `></td></tr>
 				<tr><th>Workers</th><td><input id="settings_workers" type="number" value="` + strconv.Itoa(settings.Workers) + `"></td></tr>
-				<tr><th>Rate limit</th><td><input id="settings_rate_limit" type="number" value="` + strconv.Itoa(*settings.RateLimit) + `"></td></tr>
+				<tr><th>Browser instances</th><td><input id="settings_browsers" type="number" value="` + strconv.Itoa(browserInstances()) + `"></td></tr>
+				<tr><th>Pages per browser tab</th><td><input id="settings_tab_recycle" type="number" value="` + strconv.Itoa(tabRecycle()) + `"></td></tr>
+				<tr><th>Rate limit (requests/minute)</th><td><input id="settings_rate_limit" type="number" value="` + strconv.Itoa(*settings.RateLimit) + `"></td></tr>
+				<tr><th>Requests per second</th><td><input id="settings_rps" type="number" step="0.1" value="` + strconv.FormatFloat(*settings.RequestsPerSecond, 'f', -1, 64) + `"></td></tr>
+				<tr><th>Burst</th><td><input id="settings_burst" type="number" value="` + strconv.Itoa(*settings.Burst) + `"></td></tr>
+				<tr><th>Retries</th><td><input id="settings_retries" type="number" value="` + strconv.Itoa(maxRetries()) + `"></td></tr>
+				<tr><th>Retry backoff (ms)</th><td><input id="settings_retry_backoff" type="number" value="` + strconv.Itoa(int(retryBackoffBase()/time.Millisecond)) + `"></td></tr>
+				<tr><th>Connect timeout (ms)</th><td><input id="settings_connect_timeout" type="number" value="` + strconv.Itoa(settingsMillis(settings.ConnectTimeout, defaultConnectTimeout)) + `"></td></tr>
+				<tr><th>Read timeout (ms)</th><td><input id="settings_read_timeout" type="number" value="` + strconv.Itoa(settingsMillis(settings.ReadTimeout, defaultReadTimeout)) + `"></td></tr>
+				<tr><th>Total timeout (ms)</th><td><input id="settings_timeout" type="number" value="` + strconv.Itoa(settingsMillis(settings.Timeout, defaultTimeout)) + `"></td></tr>
+				<tr><th>Idle connections per host</th><td><input id="settings_idle_conns" type="number" value="` + strconv.Itoa(maxIdleConnsPerHost()) + `"></td></tr>
+				<tr><th>HTTP/2</th><td><input id="settings_http2" type="checkbox" ` + ifThenElse(settings.HTTP2 == nil || *settings.HTTP2, `checked`, "") + `></td></tr>
+				<tr><th>Requests per host at once</th><td><input id="settings_host_concurrency" type="number" value="` + strconv.Itoa(*settings.HostConcurrency) + `"></td></tr>
 				<tr><th>Output file</th><td><input id="settings_output" type="text" value="` + settings.OutputFile + `"></td></tr>
 				<tr>
 					<th>User agents</th>
@@ -249,6 +376,10 @@ func uiEditSettings() string {
 					</td>
 				</tr>
 				<tr><th>Captcha</th><td><input id="settings_captcha" type="text" value="` + settings.Captcha + `"></td></tr>
+				<tr><th>Strip URL params</th><td><input id="settings_strip_params" type="text" value="` + strings.Join(settings.StripParams, ", ") + `"></td></tr>
+				<tr><th>Block resource types (JavaScript mode)</th><td><input id="settings_block_types" type="text" value="` + strings.Join(settings.BlockResourceTypes, ", ") + `"></td></tr>
+				<tr><th>Block URL patterns (one per line)</th><td><textarea id="settings_block_urls">` + strings.Join(settings.BlockURLPatterns, "\n") + `</textarea></td></tr>
+				<tr><th>Honor canonical links</th><td><input id="settings_canonical" type="checkbox" ` + ifThenElse(*settings.CanonicalLinks, `checked`, "") + `></td></tr>
 				<tr>
 					<th>Proxy</th>
 					<td>
@@ -304,12 +435,36 @@ func saveMap(ui lorca.UI) {
 		code := fmt.Sprintf(`document.getElementById("txt_starturl%d").value;`, i+1)
 		sitemap.StartURL = append(sitemap.StartURL, fmt.Sprint(ui.Eval(code)))
 	}
+	maxDepth, _ := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("txt_max_depth").value;`)))
+	sitemap.MaxDepth = nil
+	if maxDepth > 0 {
+		sitemap.MaxDepth = newInt(maxDepth)
+	}
+	maxPages, _ := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("txt_max_pages").value;`)))
+	sitemap.MaxPages = nil
+	if maxPages > 0 {
+		sitemap.MaxPages = newInt(maxPages)
+	}
+	sitemap.AllowedDomains = splitLines(fmt.Sprint(ui.Eval(`document.getElementById("txt_allowed_domains").value;`)))
+	sitemap.IncludePatterns = splitLines(fmt.Sprint(ui.Eval(`document.getElementById("txt_include_patterns").value;`)))
+	sitemap.ExcludePatterns = splitLines(fmt.Sprint(ui.Eval(`document.getElementById("txt_exclude_patterns").value;`)))
+	sitemap.Robots = fmt.Sprint(ui.Eval(`document.getElementById("txt_robots").value;`))
+	sitemap.Wait = readWait(ui, "txt")
+	sitemap.FullHTML = nil
+	if fmt.Sprint(ui.Eval(`document.getElementById("txt_full_html").checked.toString();`)) == "true" {
+		sitemap.FullHTML = newBool(true)
+	}
 
 	if fmt.Sprint(ui.Eval(`document.getElementById("login").checked.toString();`)) == "true" {
 		sitemap.Login = &login{
-			URL:      fmt.Sprint(ui.Eval(`document.getElementById("txt_login_url").value;`)),
-			Username: fmt.Sprint(ui.Eval(`document.getElementById("txt_login_username").value;`)),
-			Password: fmt.Sprint(ui.Eval(`document.getElementById("txt_login_password").value;`)),
+			URL:            fmt.Sprint(ui.Eval(`document.getElementById("txt_login_url").value;`)),
+			Username:       fmt.Sprint(ui.Eval(`document.getElementById("txt_login_username").value;`)),
+			Password:       fmt.Sprint(ui.Eval(`document.getElementById("txt_login_password").value;`)),
+			FormSelector:   fmt.Sprint(ui.Eval(`document.getElementById("txt_login_form").value;`)),
+			UsernameField:  fmt.Sprint(ui.Eval(`document.getElementById("txt_login_username_field").value;`)),
+			PasswordField:  fmt.Sprint(ui.Eval(`document.getElementById("txt_login_password_field").value;`)),
+			SubmitSelector: fmt.Sprint(ui.Eval(`document.getElementById("txt_login_submit").value;`)),
+			Wait:           readWait(ui, "txt_login"),
 		}
 	} else {
 		sitemap.Login = nil
@@ -328,7 +483,7 @@ func uiEditMap() string {
 				<title>Edit sitemap</title>
 				<style>
 					` + globalStyles + `
-					input:not([type='checkbox']), label:not([for="login"]) {
+					input:not([type='checkbox']), textarea, label:not([for="login"]):not([for="txt_full_html"]) {
 						display: block;
 					}
 				.hide {
@@ -344,24 +499,60 @@ func uiEditMap() string {
 	for i, e := range sitemap.StartURL {
 		page += `<input type="text" placeholder="Enter start URL" id="txt_starturl` + strconv.Itoa(i+1) + `" value="` + e + `"></input>`
 	}
-	sitemap.Login = &login{
-		URL:      "",
-		Username: "",
-		Password: "",
+	account := login{}
+	if sitemap.Login != nil {
+		account = *sitemap.Login
+	}
+	maxDepth, maxPages := 0, 0
+	if sitemap.MaxDepth != nil {
+		maxDepth = *sitemap.MaxDepth
+	}
+	if sitemap.MaxPages != nil {
+		maxPages = *sitemap.MaxPages
 	}
 	page += `</div>
 				<button onclick=removeSiteURL()>-</button>
 				<button onclick=addSiteURL()>+</button>
 				<br /><br />
+				<label for="txt_max_depth">Max depth (0 for unlimited): </label>
+				<input type="number" id="txt_max_depth" value="` + strconv.Itoa(maxDepth) + `"></input>
+				<label for="txt_max_pages">Max pages (0 for unlimited): </label>
+				<input type="number" id="txt_max_pages" value="` + strconv.Itoa(maxPages) + `"></input>
+				<label for="txt_allowed_domains">Allowed domains (one per line): </label>
+				<textarea id="txt_allowed_domains">` + strings.Join(sitemap.AllowedDomains, "\n") + `</textarea>
+				<label for="txt_include_patterns">Include URL patterns (one per line): </label>
+				<textarea id="txt_include_patterns">` + strings.Join(sitemap.IncludePatterns, "\n") + `</textarea>
+				<label for="txt_exclude_patterns">Exclude URL patterns (one per line): </label>
+				<textarea id="txt_exclude_patterns">` + strings.Join(sitemap.ExcludePatterns, "\n") + `</textarea>
+				<label for="txt_robots">robots.txt: </label>
+				<select id="txt_robots">
+					<option value="strict" ` + ifThenElse(sitemap.Robots != "advisory", `selected`, "") + `>Strict (skip disallowed URLs)</option>
+					<option value="advisory" ` + ifThenElse(sitemap.Robots == "advisory", `selected`, "") + `>Advisory (report only)</option>
+				</select>
+				<label for="txt_wait_type">Wait for (JavaScript mode): </label>
+				` + uiWaitInputs("txt", sitemap.Wait) + `
+				<label for="txt_full_html">Capture full HTML document</label>
+				<input type="checkbox" id="txt_full_html" ` + ifThenElse(sitemap.FullHTML != nil && *sitemap.FullHTML, `checked`, "") + `></input>
+				<br /><br />
 				<label for="login">Require login</label>
-				<input type="checkbox" id="login" ` + ifThenElse(sitemap.Login.URL == "", ``, `checked`) + `></input>
-				<div id="show_login"  ` + ifThenElse(sitemap.Login.URL == "", ` class="hide"`, "") + `>
+				<input type="checkbox" id="login" ` + ifThenElse(account.URL == "", ``, `checked`) + `></input>
+				<div id="show_login"  ` + ifThenElse(account.URL == "", ` class="hide"`, "") + `>
 					<label for="txt_login_url">Login URL: </label>
-					<input type="text" placeholder="Enter login url" id="txt_login_url" value="` + sitemap.Login.URL + `"></input>
+					<input type="text" placeholder="Enter login url" id="txt_login_url" value="` + account.URL + `"></input>
 					<label for="txt_login_username">Username: </label>
-					<input type="text" placeholder="Enter username" id="txt_login_username" value="` + sitemap.Login.Username + `"></input>
+					<input type="text" placeholder="Enter username" id="txt_login_username" value="` + account.Username + `"></input>
 					<label for="txt_login_password">Password: </label>
-					<input type="text" placeholder="Enter password" id="txt_login_password" value="` + sitemap.Login.Password + `"></input>
+					<input type="text" placeholder="Enter password" id="txt_login_password" value="` + account.Password + `"></input>
+					<label for="txt_login_form">Form selector: </label>
+					<input type="text" placeholder="form" id="txt_login_form" value="` + account.FormSelector + `"></input>
+					<label for="txt_login_username_field">Username field name: </label>
+					<input type="text" placeholder="username" id="txt_login_username_field" value="` + account.UsernameField + `"></input>
+					<label for="txt_login_password_field">Password field name: </label>
+					<input type="text" placeholder="password" id="txt_login_password_field" value="` + account.PasswordField + `"></input>
+					<label for="txt_login_submit">Submit selector: </label>
+					<input type="text" placeholder="[type=&quot;submit&quot;]" id="txt_login_submit" value="` + account.SubmitSelector + `"></input>
+					<label for="txt_login_wait_type">Wait after submit (browser login): </label>
+					` + uiWaitInputs("txt_login", account.Wait) + `
 				</div>
 				<button onclick=saveMap()>Save</button>
 				<script>
@@ -486,6 +677,7 @@ func saveSelector(ui lorca.UI, index int) {
 		el.ParentSelectors = append(el.ParentSelectors, fmt.Sprint(ui.Eval(code)))
 	}
 	el.Selector = fmt.Sprint(ui.Eval(`document.getElementById("map_selector").value;`))
+	el.SelectorKind = fmt.Sprint(ui.Eval(`document.getElementById("map_kind").value;`))
 	el.Multiple = newBool(fmt.Sprint(ui.Eval(`document.getElementById("map_multiple").checked.toString();`)) == "true")
 	el.Regex = fmt.Sprint(ui.Eval(`document.getElementById("map_regex").value;`))
 	intA, err := strconv.Atoi(fmt.Sprint(ui.Eval(`document.getElementById("map_delay").value;`)))
@@ -510,6 +702,18 @@ func saveSelector(ui lorca.UI, index int) {
 	el.ClickSelector = fmt.Sprint(ui.Eval(`document.getElementById("map_csl").value;`))
 	el.ClickType = fmt.Sprint(ui.Eval(`document.getElementById("map_cty").value;`))
 	el.ClickElementUnique = fmt.Sprint(ui.Eval(`document.getElementById("map_ceu").value;`))
+	el.Wait = readWait(ui, "map")
+	el.ResponseURLRegex = fmt.Sprint(ui.Eval(`document.getElementById("map_rur").value;`))
+	el.ResponseMethod = fmt.Sprint(ui.Eval(`document.getElementById("map_rme").value;`))
+	el.JSONPath = fmt.Sprint(ui.Eval(`document.getElementById("map_jpa").value;`))
+	el.PDF = nil
+	if fmt.Sprint(ui.Eval(`document.getElementById("map_pdf").checked.toString();`)) == "true" {
+		el.PDF = newBool(true)
+	}
+	el.MaxClicks = readOptionalInt(ui, "map_mcl")
+	el.ClickTimeout = readOptionalInt(ui, "map_cto")
+	el.MaxScrolls = readOptionalInt(ui, "map_msc")
+	el.ScrollTimeout = readOptionalInt(ui, "map_sto")
 
 	sitemap.Selectors[index] = el
 	writeJSON()
@@ -579,6 +783,7 @@ func uiEditSelector(index int) string {
 							<option value="SelectorLink" ` + ifThenElse(el.Type == "SelectorLink", `selected`, "") + `>Selector Link</option>
 							<option value="SelectorPopupLink" ` + ifThenElse(el.Type == "SelectorPopupLink", `selected`, "") + `>Selector Popup Link</option>
 							<option value="SelectorImage" ` + ifThenElse(el.Type == "SelectorImage", `selected`, "") + `>Selector Image</option>
+							<option value="SelectorFile" ` + ifThenElse(el.Type == "SelectorFile", `selected`, "") + `>Selector File</option>
 							<option value="SelectorTable" ` + ifThenElse(el.Type == "SelectorTable", `selected`, "") + `>Selector Table</option>
 							<option value="SelectorElementAttribute" ` + ifThenElse(el.Type == "SelectorElementAttribute", `selected`, "") + `>Selector Element Attribute</option>
 							<option value="SelectorHTML" ` + ifThenElse(el.Type == "SelectorHTML", `selected`, "") + `>Selector HTML</option>
@@ -586,14 +791,16 @@ func uiEditSelector(index int) string {
 							<option value="SelectorElementScroll" ` + ifThenElse(el.Type == "SelectorElementScroll", `selected`, "") + `>Selector Element Scroll</option>
 							<option value="SelectorElementClick" ` + ifThenElse(el.Type == "SelectorElementClick", `selected`, "") + `>Selector Element Click</option>
 							<option value="SelectorGroup" ` + ifThenElse(el.Type == "SelectorGroup", `selected`, "") + `>Selector Group</option>
-							<option value="SelectorSitemapXmlLink" ` + ifThenElse(el.Type == "SelectorSitemapXmlLink", `selected`, "") + `>Selector Sitemap Xml Link</option>
+							<option value="SelectorSitemapXML" ` + ifThenElse(el.Type == "SelectorSitemapXML", `selected`, "") + `>Selector Sitemap XML</option>
+							<option value="SelectorNetworkResponse" ` + ifThenElse(el.Type == "SelectorNetworkResponse", `selected`, "") + `>Selector Network Response</option>
+							<option value="SelectorScreenshot" ` + ifThenElse(el.Type == "SelectorScreenshot", `selected`, "") + `>Selector Screenshot</option>
 						</select>
 					</tr>
 					<tr id="attr_tr"`+ ifThenElse(el.Type == "SelectorElementAttribute", "", `class="hide"`)+`>
 						<th>Attribute name</th>
 						<td><input type ="text" id="map_attr" value="` + el.AttributeName + `"></td>
 					</tr>
-					<tr id="xml_tr"`+ ifThenElse(el.Type == "SelectorSitemapXmlLink", "", `class="hide"`)+`>
+					<tr id="xml_tr"`+ ifThenElse(el.Type == "SelectorSitemapXML", "", `class="hide"`)+`>
 						<th>Sitemap.xml Urls</th>
 						<td>
 							<div id="sitemaps">`
@@ -605,11 +812,11 @@ func uiEditSelector(index int) string {
 							<button onclick=addSitemap()>+</button>
 						</td>
 					</tr>
-					<tr id="fur_tr"`+ ifThenElse(el.Type == "SelectorSitemapXmlLink", "", `class="hide"`)+`>
+					<tr id="fur_tr"`+ ifThenElse(el.Type == "SelectorSitemapXML", "", `class="hide"`)+`>
 						<th>found url regex</th>
 						<td><input type ="text" id="map_fur" value="` + el.FoundUrlRegex + `"></td>
 					</tr>
-					<tr id="mip_tr"`+ ifThenElse(el.Type == "SelectorSitemapXmlLink", "", `class="hide"`)+`>
+					<tr id="mip_tr"`+ ifThenElse(el.Type == "SelectorSitemapXML", "", `class="hide"`)+`>
 						<th>minimum priority</th>
 						<td>`
 	if el.MinimumPriority != nil {
@@ -632,22 +839,54 @@ func uiEditSelector(index int) string {
 							</select>
 						</td>
 					</tr>
+					<tr id="rur_tr"`+ ifThenElse(el.Type == "SelectorNetworkResponse", "", `class="hide"`)+`>
+						<th>Response URL regex</th>
+						<td><input type ="text" id="map_rur" value="` + el.ResponseURLRegex + `"></td>
+					</tr>
+					<tr id="rme_tr"`+ ifThenElse(el.Type == "SelectorNetworkResponse", "", `class="hide"`)+`>
+						<th>Response method</th>
+						<td><input type ="text" id="map_rme" placeholder="Any" value="` + el.ResponseMethod + `"></td>
+					</tr>
+					<tr id="jpa_tr"`+ ifThenElse(el.Type == "SelectorNetworkResponse", "", `class="hide"`)+`>
+						<th>JSON path</th>
+						<td><input type ="text" id="map_jpa" placeholder="$.data.items[*]" value="` + el.JSONPath + `"></td>
+					</tr>
+					<tr id="pdf_tr"`+ ifThenElse(el.Type == "SelectorScreenshot", "", `class="hide"`)+`>
+						<th>Also save PDF</th>
+						<td><input type="checkbox" id="map_pdf" ` + ifThenElse(el.PDF != nil && *el.PDF, `checked`, "") + `></td>
+					</tr>
+					<tr id="msc_tr"`+ ifThenElse(el.Type == "SelectorElementScroll", "", `class="hide"`)+`>
+						<th>Max scrolls</th>
+						<td><input type="number" id="map_msc" placeholder="` + strconv.Itoa(defaultMaxScrolls) + `" value="` + optionalInt(el.MaxScrolls) + `"></td>
+					</tr>
+					<tr id="sto_tr"`+ ifThenElse(el.Type == "SelectorElementScroll", "", `class="hide"`)+`>
+						<th>Scroll timeout (ms)</th>
+						<td><input type="number" id="map_sto" placeholder="` + strconv.Itoa(int(defaultScrollTimeout/time.Millisecond)) + `" value="` + optionalInt(el.ScrollTimeout) + `"></td>
+					</tr>
 					<tr id="ceu_tr"`+ ifThenElse(el.Type == "SelectorElementClick", "", `class="hide"`)+`>
 						<th>Click element uniqueness</th>
 						<td>
 							<select id="map_ceu">
-								<option value="text" ` + ifThenElse(el.Type == "text", `selected`, "") + `>text</option>
-								<option value="htmlText" ` + ifThenElse(el.Type == "htmlText", `selected`, "") + `>html + text</option>
-								<option value="html" ` + ifThenElse(el.Type == "html", `selected`, "") + `>html</option>
-								<option value="css" ` + ifThenElse(el.Type == "css", `selected`, "") + `>css</option>
+								<option value="text" ` + ifThenElse(el.ClickElementUnique == "text", `selected`, "") + `>text</option>
+								<option value="htmlText" ` + ifThenElse(el.ClickElementUnique == "htmlText", `selected`, "") + `>html + text</option>
+								<option value="html" ` + ifThenElse(el.ClickElementUnique == "html", `selected`, "") + `>html</option>
+								<option value="css" ` + ifThenElse(el.ClickElementUnique == "css", `selected`, "") + `>css</option>
 							</select>
 						</td>
 					</tr>
+					<tr id="mcl_tr"`+ ifThenElse(el.Type == "SelectorElementClick", "", `class="hide"`)+`>
+						<th>Max clicks</th>
+						<td><input type="number" id="map_mcl" placeholder="` + strconv.Itoa(defaultMaxClicks) + `" value="` + optionalInt(el.MaxClicks) + `"></td>
+					</tr>
+					<tr id="cto_tr"`+ ifThenElse(el.Type == "SelectorElementClick", "", `class="hide"`)+`>
+						<th>Click timeout (ms)</th>
+						<td><input type="number" id="map_cto" placeholder="` + strconv.Itoa(int(defaultClickTimeout/time.Millisecond)) + `" value="` + optionalInt(el.ClickTimeout) + `"></td>
+					</tr>
 	`
 	if el.Download != nil {
-		page += `<tr id="download" ` + ifThenElse(el.Type == "SelectorImage", "", `class="hide"`) + `><th>Download</th><td><input type="checkbox" id="download"` + ifThenElse(*el.Download, "checked", "") + `></input></td></tr>`
+		page += `<tr id="download_tr" ` + ifThenElse(el.Type == "SelectorImage" || el.Type == "SelectorFile" || el.Type == "SelectorNetworkResponse", "", `class="hide"`) + `><th>Download</th><td><input type="checkbox" id="download"` + ifThenElse(*el.Download, "checked", "") + `></input></td></tr>`
 	} else {
-		page += `<tr id="download" ` + ifThenElse(el.Type == "SelectorImage", "", `class="hide"`) + `><th>Download</th><td><input type="checkbox" id="download"></input></td></tr>`
+		page += `<tr id="download_tr" ` + ifThenElse(el.Type == "SelectorImage" || el.Type == "SelectorFile" || el.Type == "SelectorNetworkResponse", "", `class="hide"`) + `><th>Download</th><td><input type="checkbox" id="download"></input></td></tr>`
 	}
 	page += 		`<tr>
 						<th>parent selectors</th>
@@ -662,6 +901,15 @@ func uiEditSelector(index int) string {
 	page += `</select>
 						</td>
 					</tr>
+					<tr>
+						<th>selector kind</th>
+						<td>
+							<select id="map_kind">
+								<option value="css" ` + ifThenElse(!isXPath(&el), `selected`, "") + `>CSS</option>
+								<option value="xpath" ` + ifThenElse(isXPath(&el), `selected`, "") + `>XPath</option>
+							</select>
+						</td>
+					</tr>
 					<tr>
 						<th>selector</th>
 						<td>
@@ -689,6 +937,7 @@ func uiEditSelector(index int) string {
 	} else {
 		page += `<tr><th>delay</th><td><input type="number" id="map_delay" value="0"></td></tr>`
 	}
+	page += `<tr><th>wait for</th><td>` + uiWaitInputs("map", el.Wait) + `</td></tr>`
 	page += `</table>
 				<div class="buttons">
 					<button onclick=deleteSelector(` + strconv.Itoa(index) + `)>Delete</button>
@@ -699,7 +948,7 @@ func uiEditSelector(index int) string {
 					let ua = document.getElementById("sitemaps");
 
 					let select = document.getElementById("map_type");
-					let download = document.getElementById("download");
+					let download = document.getElementById("download_tr");
 					let attr_tr = document.getElementById("attr_tr");
 					let hrs_tr = document.getElementById("hrs_tr");
 					let drs_tr = document.getElementById("drs_tr");
@@ -709,17 +958,36 @@ func uiEditSelector(index int) string {
 					let csl_tr = document.getElementById("csl_tr");
 					let cty_tr = document.getElementById("cty_tr");
 					let ceu_tr = document.getElementById("ceu_tr");
+					let mcl_tr = document.getElementById("mcl_tr");
+					let cto_tr = document.getElementById("cto_tr");
+					let rur_tr = document.getElementById("rur_tr");
+					let rme_tr = document.getElementById("rme_tr");
+					let jpa_tr = document.getElementById("jpa_tr");
+					let pdf_tr = document.getElementById("pdf_tr");
+					let msc_tr = document.getElementById("msc_tr");
+					let sto_tr = document.getElementById("sto_tr");
 					select.addEventListener('change', function() {
 						download.classList.add("hide");
 						attr_tr.classList.add("hide");
 						hrs_tr.classList.add("hide");
 						drs_tr.classList.add("hide");
 						xml_tr.classList.add("hide");
+						fur_tr.classList.add("hide");
+						mip_tr.classList.add("hide");
 						csl_tr.classList.add("hide");
 						cty_tr.classList.add("hide");
 						ceu_tr.classList.add("hide");
+						mcl_tr.classList.add("hide");
+						cto_tr.classList.add("hide");
+						rur_tr.classList.add("hide");
+						rme_tr.classList.add("hide");
+						jpa_tr.classList.add("hide");
+						pdf_tr.classList.add("hide");
+						msc_tr.classList.add("hide");
+						sto_tr.classList.add("hide");
 						switch(select.value) {
 							case "SelectorImage":
+							case "SelectorFile":
 								download.classList.remove("hide");		
 								break;
 							case "SelectorElementAttribute":
@@ -729,7 +997,7 @@ func uiEditSelector(index int) string {
 								hrs_tr.classList.remove("hide");
 								drs_tr.classList.remove("hide");		
 								break;
-							case "SelectorSitemapXmlLink":
+							case "SelectorSitemapXML":
 								xml_tr.classList.remove("hide");
 								fur_tr.classList.remove("hide");
 								mip_tr.classList.remove("hide");		
@@ -737,7 +1005,22 @@ func uiEditSelector(index int) string {
 							case "SelectorElementClick":
 								csl_tr.classList.remove("hide");
 								cty_tr.classList.remove("hide");
-								ceu_tr.classList.remove("hide");		
+								ceu_tr.classList.remove("hide");
+								mcl_tr.classList.remove("hide");
+								cto_tr.classList.remove("hide");		
+								break;
+							case "SelectorNetworkResponse":
+								download.classList.remove("hide");
+								rur_tr.classList.remove("hide");
+								rme_tr.classList.remove("hide");
+								jpa_tr.classList.remove("hide");
+								break;
+							case "SelectorScreenshot":
+								pdf_tr.classList.remove("hide");
+								break;
+							case "SelectorElementScroll":
+								msc_tr.classList.remove("hide");
+								sto_tr.classList.remove("hide");
 								break;
 						}
 					});
@@ -750,19 +1033,12 @@ func uiEditSelector(index int) string {
 
 func selectedElement(ui lorca.UI, index int, str string) {
 	sitemap.Selectors[index].Selector = str
+	sitemap.Selectors[index].SelectorKind = selectorKindCSS
 	editSelector(ui, index)
 }
 
 func uiSelectElement(index int, selectURL string) string {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	if len(settings.Proxy) > 0 {
-		proxyString := settings.Proxy[0]
-		proxyURL, _ := url.Parse(proxyString)
-		transport.Proxy = http.ProxyURL(proxyURL)
-	}
-	client := &http.Client{Transport: transport}
+	client := httpClient()
 	req, err := http.NewRequest("GET", selectURL, nil)
 	if err != nil {
 		frontendLog(err)
@@ -971,6 +1247,8 @@ func bindFunctions(ui lorca.UI) error {
 }
 
 func main() {
+	flag.BoolVar(&shouldResume, "resume", false, "resume the previous crawl from its frontier file (root pages only; nested link pages of unfinished root pages are fetched again)")
+	flag.Parse()
 	readJSON()
 	if !settings.Gui {
 		scrape()
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	frontierQueued   = "queued"
+	frontierInFlight = "in-flight"
+	frontierDone     = "done"
+	frontierFailed   = "failed"
+	frontierBlocked  = "blocked"
+)
+
+var (
+	crawlFrontier *frontier
+)
+
+type frontierEntry struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+	Depth int    `json:"depth,omitempty"`
+}
+
+type frontier struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	states  map[string]string
+	depths  map[string]int
+	queue   []string
+	active  int
+	journal *os.File
+}
+
+func newFrontier() *frontier {
+	f := &frontier{states: make(map[string]string), depths: make(map[string]int)}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func frontierPath(projectID string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(projectID)
+	if name == "" {
+		name = "sitemap"
+	}
+	return name + ".frontier.jsonl"
+}
+
+func openFrontier(path string, resume bool) (*frontier, error) {
+	f := newFrontier()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		file, err := os.Open(path)
+		if err == nil {
+			scanner := bufio.NewScanner(file)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				var entry frontierEntry
+				if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+					if _, ok := f.states[entry.URL]; !ok {
+						f.queue = append(f.queue, entry.URL)
+						f.depths[entry.URL] = entry.Depth
+						crawlVisited.add(entry.URL)
+						crawlScope.reserve()
+					}
+					f.states[entry.URL] = entry.State
+				}
+			}
+			_ = file.Close()
+		}
+		var queue []string
+		for _, pageURL := range f.queue {
+			state := f.states[pageURL]
+			if state == frontierQueued || state == frontierInFlight {
+				f.states[pageURL] = frontierQueued
+				queue = append(queue, pageURL)
+			}
+		}
+		f.queue = queue
+		f.active = len(queue)
+	}
+	var err error
+	f.journal, err = os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *frontier) record(pageURL, state string) {
+	f.states[pageURL] = state
+	if f.journal == nil {
+		return
+	}
+	line, err := json.Marshal(frontierEntry{URL: pageURL, State: state, Depth: f.depths[pageURL]})
+	if err == nil {
+		_, err = f.journal.Write(append(line, '\n'))
+	}
+	if err != nil {
+		logErrors(err)
+	}
+}
+
+func (f *frontier) push(pageURL string, depth int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.states[pageURL]; ok {
+		return false
+	}
+	if !crawlVisited.add(pageURL) || !crawlScope.reserve() {
+		return false
+	}
+	f.depths[pageURL] = depth
+	f.record(pageURL, frontierQueued)
+	f.queue = append(f.queue, pageURL)
+	f.active++
+	f.cond.Broadcast()
+	return true
+}
+
+func (f *frontier) next() (string, int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.queue) == 0 && f.active > 0 {
+		f.cond.Wait()
+	}
+	if len(f.queue) == 0 {
+		return "", 0, false
+	}
+	pageURL := f.queue[0]
+	f.queue = f.queue[1:]
+	f.record(pageURL, frontierInFlight)
+	return pageURL, f.depths[pageURL], true
+}
+
+func (f *frontier) finish(pageURL, state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(pageURL, state)
+	f.active--
+	f.cond.Broadcast()
+}
+
+func (f *frontier) close() {
+	if f.journal == nil {
+		return
+	}
+	err := f.journal.Close()
+	if err != nil {
+		logErrors(err)
+	}
+}
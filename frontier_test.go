@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func drainFrontier(f *frontier) map[string]int {
+	pages := make(map[string]int)
+	for f.active > 0 && len(f.queue) > 0 {
+		pageURL, depth, ok := f.next()
+		if !ok {
+			break
+		}
+		pages[pageURL] = depth
+		f.finish(pageURL, frontierDone)
+	}
+	return pages
+}
+
+func TestFrontierResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.frontier.jsonl")
+	tests := []struct {
+		name     string
+		finished []string
+		failed   []string
+		want     map[string]int
+	}{
+		{
+			name: "nothing finished",
+			want: map[string]int{"http://a.test/1": 0, "http://a.test/2": 1, "http://a.test/3": 2},
+		},
+		{
+			name:     "some finished",
+			finished: []string{"http://a.test/1"},
+			failed:   []string{"http://a.test/2"},
+			want:     map[string]int{"http://a.test/3": 2},
+		},
+		{
+			name:     "all finished",
+			finished: []string{"http://a.test/1", "http://a.test/2", "http://a.test/3"},
+			want:     map[string]int{},
+		},
+	}
+	for _, test := range tests {
+		crawlVisited.reset()
+		crawlScope = &scope{}
+		f, err := openFrontier(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for depth, pageURL := range []string{"http://a.test/1", "http://a.test/2", "http://a.test/3"} {
+			f.push(pageURL, depth)
+		}
+		for _, states := range []struct {
+			urls  []string
+			state string
+		}{{test.finished, frontierDone}, {test.failed, frontierFailed}} {
+			for range states.urls {
+				pageURL, _, _ := f.next()
+				f.finish(pageURL, states.state)
+			}
+		}
+		if len(f.queue) > 0 {
+			f.next()
+		}
+		f.close()
+
+		crawlVisited.reset()
+		resumed, err := openFrontier(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resumed.push("http://a.test/1", 0) {
+			t.Errorf("%s: push accepted a URL already in the journal", test.name)
+		}
+		got := drainFrontier(resumed)
+		resumed.close()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: resumed pages = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
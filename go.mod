@@ -4,8 +4,13 @@ go 1.15
 
 require (
 	github.com/PuerkitoBio/goquery v1.6.0
+	github.com/andybalholm/brotli v1.0.2
+	github.com/antchfx/htmlquery v1.2.3
+	github.com/antchfx/xpath v1.1.6
 	github.com/chromedp/cdproto v0.0.0-20201009231348-1c6a710e77de
 	github.com/chromedp/chromedp v0.5.3
 	github.com/dlclark/regexp2 v1.4.0
 	github.com/zserge/lorca v0.1.9
+	golang.org/x/net v0.0.0-20200421231249-e086a090c8fd
+	golang.org/x/text v0.3.0
 )
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConnectTimeout   = 10 * time.Second
+	defaultReadTimeout      = 30 * time.Second
+	defaultTimeout          = 2 * time.Minute
+	defaultIdleConnsPerHost = 16
+)
+
+var (
+	httpClients     = make(map[string]*http.Client)
+	httpClientsMu   sync.Mutex
+	httpClientProxy int
+)
+
+type decodingTransport struct {
+	base http.RoundTripper
+}
+
+type decodedBody struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	err      error
+}
+
+func (transport *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	}
+	response, err := transport.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	encoding := strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br":
+		response.Body = &decodedBody{body: response.Body, encoding: encoding}
+		response.Header.Del("Content-Encoding")
+		response.Header.Del("Content-Length")
+		response.ContentLength = -1
+		response.Uncompressed = true
+	}
+	return response, nil
+}
+
+func (body *decodedBody) Read(p []byte) (int, error) {
+	if body.reader == nil && body.err == nil {
+		switch body.encoding {
+		case "br":
+			body.reader = brotli.NewReader(body.body)
+		case "deflate":
+			buffered := bufio.NewReader(body.body)
+			header, err := buffered.Peek(2)
+			if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+				body.reader, body.err = zlib.NewReader(buffered)
+			} else {
+				body.reader = flate.NewReader(buffered)
+			}
+		default:
+			body.reader, body.err = gzip.NewReader(body.body)
+		}
+	}
+	if body.err != nil {
+		return 0, body.err
+	}
+	return body.reader.Read(p)
+}
+
+func (body *decodedBody) Close() error {
+	if closer, ok := body.reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return body.body.Close()
+}
+
+func settingsDuration(value *int, fallback time.Duration) time.Duration {
+	if value == nil || *value < 0 {
+		return fallback
+	}
+	return time.Duration(*value) * time.Millisecond
+}
+
+func settingsMillis(value *int, fallback time.Duration) int {
+	return int(settingsDuration(value, fallback) / time.Millisecond)
+}
+
+func maxIdleConnsPerHost() int {
+	if settings.MaxIdleConnsPerHost != nil && *settings.MaxIdleConnsPerHost > 0 {
+		return *settings.MaxIdleConnsPerHost
+	}
+	return defaultIdleConnsPerHost
+}
+
+func newSharedHTTPClient(proxy string) *http.Client {
+	connectTimeout := settingsDuration(settings.ConnectTimeout, defaultConnectTimeout)
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	idlePerHost := maxIdleConnsPerHost()
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: false},
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: settingsDuration(settings.ReadTimeout, defaultReadTimeout),
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          idlePerHost * 8,
+		MaxIdleConnsPerHost:   idlePerHost,
+		DisableCompression:    true,
+		ForceAttemptHTTP2:     settings.HTTP2 == nil || *settings.HTTP2,
+	}
+	if !transport.ForceAttemptHTTP2 {
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			logErrors(err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{
+		Transport: &decodingTransport{base: transport},
+		Jar:       sessionJar,
+		Timeout:   settingsDuration(settings.Timeout, defaultTimeout),
+	}
+}
+
+func httpClient() *http.Client {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	proxy := ""
+	if len(settings.Proxy) > 0 {
+		proxy = settings.Proxy[httpClientProxy%len(settings.Proxy)]
+		httpClientProxy++
+	}
+	client, ok := httpClients[proxy]
+	if !ok {
+		client = newSharedHTTPClient(proxy)
+		httpClients[proxy] = client
+	}
+	return client
+}
+
+func resetHTTPClients() {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	for _, client := range httpClients {
+		client.CloseIdleConnections()
+	}
+	httpClients = make(map[string]*http.Client)
+	httpClientProxy = 0
+}
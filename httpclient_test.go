@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		var err error
+		writer, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	default:
+		return data
+	}
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	return buf.Bytes()
+}
+
+func TestDecodingTransport(t *testing.T) {
+	page := []byte("<html><body>" + string(bytes.Repeat([]byte("compressed "), 100)) + "</body></html>")
+	tests := []struct {
+		name     string
+		encoding string
+		header   string
+	}{
+		{"identity", "", ""},
+		{"gzip", "gzip", "gzip"},
+		{"x-gzip", "gzip", "x-gzip"},
+		{"zlib deflate", "deflate", "deflate"},
+		{"raw deflate", "raw-deflate", "deflate"},
+		{"brotli", "br", "br"},
+	}
+	for _, test := range tests {
+		var acceptEncoding string
+		transport := &decodingTransport{base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			acceptEncoding = req.Header.Get("Accept-Encoding")
+			header := http.Header{}
+			if test.header != "" {
+				header.Set("Content-Encoding", test.header)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader(compress(t, test.encoding, page))),
+			}, nil
+		})}
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		response, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			t.Errorf("%s: read: %v", test.name, err)
+		}
+		if !bytes.Equal(body, page) {
+			t.Errorf("%s: decoded body = %q", test.name, body)
+		}
+		if acceptEncoding != "gzip, deflate, br" {
+			t.Errorf("%s: Accept-Encoding = %q", test.name, acceptEncoding)
+		}
+		if response.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%s: Content-Encoding was not removed", test.name)
+		}
+	}
+}
+
+func TestSettingsDuration(t *testing.T) {
+	tests := []struct {
+		value *int
+		want  time.Duration
+	}{
+		{nil, time.Second},
+		{newInt(-1), time.Second},
+		{newInt(0), 0},
+		{newInt(250), 250 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := settingsDuration(test.value, time.Second); got != test.want {
+			t.Errorf("settingsDuration(%v) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonPathStep struct {
+	key      string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	var steps []jsonPathStep
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+			if key == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else if key != "" {
+				steps = append(steps, jsonPathStep{key: key})
+			}
+		case '[':
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in JSON path")
+			}
+			inner := strings.TrimSpace(path[1:end])
+			path = path[end+1:]
+			if inner == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in JSON path", inner)
+				}
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			}
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			steps = append(steps, jsonPathStep{key: path[:end]})
+			path = path[end:]
+		}
+	}
+	return steps, nil
+}
+
+func (step jsonPathStep) apply(value interface{}) []interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			var values []interface{}
+			for _, child := range node {
+				values = append(values, child)
+			}
+			return values
+		}
+		if child, ok := node[step.key]; ok && !step.isIndex {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if step.wildcard {
+			return node
+		}
+		if step.isIndex {
+			index := step.index
+			if index < 0 {
+				index += len(node)
+			}
+			if index >= 0 && index < len(node) {
+				return []interface{}{node[index]}
+			}
+		}
+	}
+	return nil
+}
+
+func evalJSONPath(data interface{}, path string) ([]interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{data}
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, step.apply(value)...)
+		}
+		values = next
+	}
+	return values, nil
+}
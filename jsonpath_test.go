@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvalJSONPath(t *testing.T) {
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"data": {"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3}]},
+		"meta key": {"total": 3},
+		"tags": ["x", "y"]
+	}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$.data.items[0].name", []interface{}{"a"}},
+		{"data.items[1].id", []interface{}{2.0}},
+		{"$.data.items[-1].id", []interface{}{3.0}},
+		{"$.data.items[*].name", []interface{}{"a", "b"}},
+		{"$.data.items[*].id", []interface{}{1.0, 2.0, 3.0}},
+		{"$['meta key'].total", []interface{}{3.0}},
+		{`$["tags"][1]`, []interface{}{"y"}},
+		{"$.tags.*", []interface{}{"x", "y"}},
+		{"$.data.items[5]", nil},
+		{"$.missing.key", nil},
+		{"$.tags.name", nil},
+		{"$", []interface{}{data}},
+	}
+	for _, test := range tests {
+		got, err := evalJSONPath(data, test.path)
+		if err != nil {
+			t.Errorf("evalJSONPath(%q) error: %v", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("evalJSONPath(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestEvalJSONPathErrors(t *testing.T) {
+	for _, path := range []string{"$.items[0", "$.items[abc]"} {
+		if _, err := evalJSONPath(map[string]interface{}{}, path); err == nil {
+			t.Errorf("evalJSONPath(%q) returned no error", path)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	crawlLimiter = newRateLimiter(0, 0, 0)
+)
+
+type hostLimiter struct {
+	tokens float64
+	last   time.Time
+	next   time.Time
+	slots  chan struct{}
+}
+
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	concurrency int
+	hosts       map[string]*hostLimiter
+}
+
+func newRateLimiter(rate float64, burst, concurrency int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		concurrency: concurrency,
+		hosts:       make(map[string]*hostLimiter),
+	}
+}
+
+func settingsLimiter() *rateLimiter {
+	rate := 0.0
+	if settings.RequestsPerSecond != nil && *settings.RequestsPerSecond > 0 {
+		rate = *settings.RequestsPerSecond
+	} else if settings.RateLimit != nil && *settings.RateLimit > 0 {
+		rate = float64(*settings.RateLimit) / 60
+	}
+	burst, concurrency := 1, 0
+	if settings.Burst != nil {
+		burst = *settings.Burst
+	}
+	if settings.HostConcurrency != nil {
+		concurrency = *settings.HostConcurrency
+	}
+	return newRateLimiter(rate, burst, concurrency)
+}
+
+func (limiter *rateLimiter) host(host string) *hostLimiter {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	bucket, ok := limiter.hosts[host]
+	if !ok {
+		bucket = &hostLimiter{tokens: limiter.burst, last: time.Now()}
+		if limiter.concurrency > 0 {
+			bucket.slots = make(chan struct{}, limiter.concurrency)
+		}
+		limiter.hosts[host] = bucket
+	}
+	return bucket
+}
+
+func (limiter *rateLimiter) acquire(pageURL string, crawlDelay time.Duration) func() {
+	host := pageURL
+	uri, err := url.Parse(pageURL)
+	if err == nil {
+		host = uri.Host
+	}
+	bucket := limiter.host(host)
+	if bucket.slots != nil {
+		bucket.slots <- struct{}{}
+	}
+	limiter.mu.Lock()
+	now := time.Now()
+	start := now
+	if limiter.rate > 0 {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * limiter.rate
+		if bucket.tokens > limiter.burst {
+			bucket.tokens = limiter.burst
+		}
+		bucket.last = now
+		bucket.tokens--
+		if bucket.tokens < 0 {
+			start = now.Add(time.Duration(-bucket.tokens / limiter.rate * float64(time.Second)))
+		}
+	}
+	if start.Before(bucket.next) {
+		start = bucket.next
+	}
+	if crawlDelay > 0 {
+		bucket.next = start.Add(crawlDelay)
+	}
+	limiter.mu.Unlock()
+	time.Sleep(start.Sub(now))
+	return func() {
+		if bucket.slots != nil {
+			<-bucket.slots
+		}
+	}
+}
+
+func acquireRequest(pageURL, userAgent string) (func(), error) {
+	var crawlDelay time.Duration
+	uri, err := url.Parse(pageURL)
+	if err != nil || uri.Path != "/robots.txt" {
+		var allowed bool
+		allowed, crawlDelay = crawlRobots.check(pageURL, userAgent)
+		if !allowed && sitemap.Robots != "advisory" {
+			return nil, &robotsError{URL: pageURL}
+		}
+	}
+	return crawlLimiter.acquire(pageURL, crawlDelay), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func timeAcquire(limiter *rateLimiter, pageURL string, crawlDelay time.Duration) time.Duration {
+	start := time.Now()
+	limiter.acquire(pageURL, crawlDelay)()
+	return time.Since(start)
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := newRateLimiter(10, 3, 0)
+	for i := 0; i < 3; i++ {
+		if wait := timeAcquire(limiter, "http://example.com/", 0); wait > 30*time.Millisecond {
+			t.Errorf("request %d within the burst waited %v", i+1, wait)
+		}
+	}
+	if wait := timeAcquire(limiter, "http://example.com/", 0); wait < 70*time.Millisecond {
+		t.Errorf("request past the burst waited %v, want about 100ms", wait)
+	}
+	if wait := timeAcquire(limiter, "http://example.org/", 0); wait > 30*time.Millisecond {
+		t.Errorf("request to another host waited %v", wait)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := newRateLimiter(20, 1, 0)
+	timeAcquire(limiter, "http://example.com/", 0)
+	time.Sleep(60 * time.Millisecond)
+	if wait := timeAcquire(limiter, "http://example.com/", 0); wait > 30*time.Millisecond {
+		t.Errorf("request after the bucket refilled waited %v", wait)
+	}
+	if wait := timeAcquire(limiter, "http://example.com/", 0); wait < 30*time.Millisecond {
+		t.Errorf("request on an empty bucket waited %v, want about 50ms", wait)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := newRateLimiter(0, 0, 0)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.acquire("http://example.com/", 0)()
+	}
+	if wait := time.Since(start); wait > 50*time.Millisecond {
+		t.Errorf("unlimited requests waited %v", wait)
+	}
+}
+
+func TestRateLimiterCrawlDelay(t *testing.T) {
+	limiter := newRateLimiter(0, 0, 0)
+	if wait := timeAcquire(limiter, "http://example.com/a", 80*time.Millisecond); wait > 30*time.Millisecond {
+		t.Errorf("first request waited %v", wait)
+	}
+	if wait := timeAcquire(limiter, "http://example.com/b", 80*time.Millisecond); wait < 60*time.Millisecond {
+		t.Errorf("second request waited %v, want about the 80ms crawl delay", wait)
+	}
+	if wait := timeAcquire(limiter, "http://example.org/", 80*time.Millisecond); wait > 30*time.Millisecond {
+		t.Errorf("request to another host waited %v", wait)
+	}
+}
+
+func TestRateLimiterSlots(t *testing.T) {
+	limiter := newRateLimiter(0, 0, 1)
+	release := limiter.acquire("http://example.com/a", 0)
+	acquired := make(chan func())
+	go func() {
+		acquired <- limiter.acquire("http://example.com/b", 0)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second request got a slot while the first still held it")
+	case <-time.After(50 * time.Millisecond):
+	}
+	limiter.acquire("http://example.org/", 0)()
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("second request didn't get the released slot")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+var (
+	crawlReport = &runReport{}
+)
+
+type jobError struct {
+	URL        string `json:"url"`
+	Stage      string `json:"stage"`
+	SelectorID string `json:"selectorId,omitempty"`
+	Error      string `json:"error"`
+	Attempts   int    `json:"attempts"`
+}
+
+func newJobError(pageURL, stage, selectorID string, err error) jobError {
+	return jobError{
+		URL:        pageURL,
+		Stage:      stage,
+		SelectorID: selectorID,
+		Error:      err.Error(),
+		Attempts:   retryAttempts(err),
+	}
+}
+
+type runReport struct {
+	mu             sync.Mutex
+	Pages          int                 `json:"pages"`
+	BlockedCount   int                 `json:"blockedCount"`
+	Blocked        []string            `json:"blocked,omitempty"`
+	FailedCount    int                 `json:"failedCount"`
+	DuplicateCount int                 `json:"duplicateCount"`
+	Duplicates     []string            `json:"duplicates,omitempty"`
+	ReloadCount    int                 `json:"reloadCount"`
+	Reloads        []string            `json:"reloads,omitempty"`
+	ErrorCount     int                 `json:"errorCount"`
+	Errors         []jobError          `json:"errors,omitempty"`
+	PageMeta       map[string]pageMeta `json:"pageMeta,omitempty"`
+}
+
+func (report *runReport) page() {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.Pages++
+}
+
+func (report *runReport) block(pageURL string) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.BlockedCount++
+	report.Blocked = append(report.Blocked, pageURL)
+}
+
+func (report *runReport) duplicate(pageURL string) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.DuplicateCount++
+	report.Duplicates = append(report.Duplicates, pageURL)
+}
+
+func (report *runReport) reload(pageURL string) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.ReloadCount++
+	report.Reloads = append(report.Reloads, pageURL)
+}
+
+func (report *runReport) meta(pageURL string, meta pageMeta) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	if report.PageMeta == nil {
+		report.PageMeta = make(map[string]pageMeta)
+	}
+	report.PageMeta[pageURL] = meta
+}
+
+func (report *runReport) fail() {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.FailedCount++
+}
+
+func (report *runReport) errors(jobErrors []jobError) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.ErrorCount += len(jobErrors)
+	report.Errors = append(report.Errors, jobErrors...)
+}
+
+func reportPath() string {
+	if index := strings.LastIndex(settings.OutputFile, "."); index >= 0 {
+		return settings.OutputFile[:index] + ".report.json"
+	}
+	return settings.OutputFile + ".report.json"
+}
+
+func writeRunReport() error {
+	crawlReport.mu.Lock()
+	defer crawlReport.mu.Unlock()
+	data, err := json.MarshalIndent(crawlReport, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(reportPath(), data, 0644)
+}
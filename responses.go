@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/dlclark/regexp2"
+	"strings"
+	"sync"
+)
+
+type networkResponse struct {
+	URL      string
+	Method   string
+	Status   int64
+	MimeType string
+	Body     []byte
+}
+
+type documentWatch struct {
+	mu        sync.Mutex
+	frameID   cdp.FrameID
+	requestID network.RequestID
+	response  *network.Response
+	failure   string
+}
+
+type responseMatcher struct {
+	re     *regexp2.Regexp
+	method string
+}
+
+type responseCapture struct {
+	mu        sync.Mutex
+	matchers  []responseMatcher
+	requests  map[network.RequestID]*networkResponse
+	finished  []network.RequestID
+	responses []networkResponse
+}
+
+func watchDocument(ctx context.Context) (*documentWatch, context.CancelFunc) {
+	document := &documentWatch{}
+	listenCtx, cancel := context.WithCancel(ctx)
+	chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+		document.mu.Lock()
+		defer document.mu.Unlock()
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if ev.Type == network.ResourceTypeDocument && ev.FrameID == document.frameID {
+				document.requestID, document.response, document.failure = ev.RequestID, nil, ""
+			}
+		case *network.EventResponseReceived:
+			if ev.RequestID == document.requestID {
+				document.response = ev.Response
+			}
+		case *network.EventLoadingFailed:
+			if ev.RequestID == document.requestID && !ev.Canceled {
+				document.failure = ev.ErrorText
+			}
+		}
+	})
+	return document, cancel
+}
+
+func (document *documentWatch) enable() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		err := network.Enable().Do(ctx)
+		if err != nil {
+			return err
+		}
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return err
+		}
+		document.mu.Lock()
+		defer document.mu.Unlock()
+		document.frameID = tree.Frame.ID
+		return nil
+	})
+}
+
+func (document *documentWatch) check(pageURL string, status *int) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		document.mu.Lock()
+		defer document.mu.Unlock()
+		if document.failure != "" {
+			return &navigationError{URL: pageURL, Text: document.failure}
+		}
+		if document.response == nil {
+			return nil
+		}
+		*status = int(document.response.Status)
+		if *status >= 400 {
+			return &httpStatusError{
+				URL:        pageURL,
+				StatusCode: *status,
+				RetryAfter: retryAfter(headerValue(document.response.Headers, "Retry-After")),
+			}
+		}
+		return nil
+	})
+}
+
+func headerValue(headers network.Headers, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+func newResponseMatcher(selector *selectors) (responseMatcher, error) {
+	matcher := responseMatcher{method: strings.ToUpper(strings.TrimSpace(selector.ResponseMethod))}
+	if selector.ResponseURLRegex != "" {
+		re, err := regexp2.Compile(selector.ResponseURLRegex, 0)
+		if err != nil {
+			return matcher, err
+		}
+		matcher.re = re
+	}
+	return matcher, nil
+}
+
+func (matcher responseMatcher) matches(URL, method string) bool {
+	if matcher.method != "" && matcher.method != strings.ToUpper(method) {
+		return false
+	}
+	if matcher.re == nil {
+		return true
+	}
+	match, _ := matcher.re.MatchString(URL)
+	return match
+}
+
+func newResponseCapture(captures []*selectors) (*responseCapture, error) {
+	capture := &responseCapture{}
+	for _, selector := range captures {
+		matcher, err := newResponseMatcher(selector)
+		if err != nil {
+			return nil, err
+		}
+		capture.matchers = append(capture.matchers, matcher)
+	}
+	return capture, nil
+}
+
+func (capture *responseCapture) watch(ctx context.Context) context.CancelFunc {
+	capture.mu.Lock()
+	capture.requests = make(map[network.RequestID]*networkResponse)
+	capture.finished = nil
+	capture.responses = nil
+	capture.mu.Unlock()
+	listenCtx, cancel := context.WithCancel(ctx)
+	if len(capture.matchers) == 0 {
+		return cancel
+	}
+	chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+		capture.mu.Lock()
+		defer capture.mu.Unlock()
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			for _, matcher := range capture.matchers {
+				if matcher.matches(ev.Request.URL, ev.Request.Method) {
+					capture.requests[ev.RequestID] = &networkResponse{URL: ev.Request.URL, Method: ev.Request.Method}
+					break
+				}
+			}
+		case *network.EventResponseReceived:
+			if response, ok := capture.requests[ev.RequestID]; ok {
+				response.Status = ev.Response.Status
+				response.MimeType = ev.Response.MimeType
+			}
+		case *network.EventLoadingFinished:
+			if _, ok := capture.requests[ev.RequestID]; ok {
+				capture.finished = append(capture.finished, ev.RequestID)
+			}
+		}
+	})
+	return cancel
+}
+
+func (capture *responseCapture) enable() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(capture.matchers) == 0 {
+			return nil
+		}
+		return network.Enable().Do(ctx)
+	})
+}
+
+func (capture *responseCapture) collect() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		capture.mu.Lock()
+		finished := capture.finished
+		capture.finished = nil
+		capture.mu.Unlock()
+		for _, requestID := range finished {
+			body, err := network.GetResponseBody(requestID).Do(ctx)
+			if err != nil {
+				logErrors(err)
+				continue
+			}
+			capture.mu.Lock()
+			response := *capture.requests[requestID]
+			capture.mu.Unlock()
+			response.Body = body
+			capture.responses = append(capture.responses, response)
+		}
+		return nil
+	})
+}
+
+func responseData(body []byte) interface{} {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&data) != nil {
+		return string(body)
+	}
+	return data
+}
+
+func selectorNetworkResponse(responses []networkResponse, selector *selectors) ([]interface{}, error) {
+	matcher, err := newResponseMatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+	var records []interface{}
+	var responseErr error
+	for _, response := range responses {
+		if !matcher.matches(response.URL, response.Method) {
+			continue
+		}
+		data := responseData(response.Body)
+		if selector.JSONPath != "" {
+			values, err := evalJSONPath(data, selector.JSONPath)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) == 1 {
+				data = values[0]
+			} else {
+				data = values
+			}
+		}
+		record := map[string]interface{}{"url": response.URL, "status": response.Status, "data": data}
+		if *selector.Download {
+			store, err := assetStorage()
+			if err == nil {
+				var asset assetRecord
+				asset, err = store.save(response.URL, response.MimeType, bytes.NewReader(response.Body))
+				if err == nil {
+					record["hash"], record["path"] = asset.Hash, asset.Path
+				}
+			}
+			if err != nil {
+				responseErr = err
+			}
+		}
+		records = append(records, record)
+		if !*selector.Multiple {
+			break
+		}
+	}
+	return records, responseErr
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxRetryBackoff = 30 * time.Second
+)
+
+var (
+	transientNavigationErrors = map[string]bool{
+		"net::ERR_CONNECTION_CLOSED":    true,
+		"net::ERR_CONNECTION_REFUSED":   true,
+		"net::ERR_CONNECTION_RESET":     true,
+		"net::ERR_CONNECTION_TIMED_OUT": true,
+		"net::ERR_EMPTY_RESPONSE":       true,
+		"net::ERR_TIMED_OUT":            true,
+	}
+)
+
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s: code %d", e.URL, e.StatusCode)
+}
+
+type navigationError struct {
+	URL  string
+	Text string
+}
+
+func (e *navigationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.URL, e.Text)
+}
+
+type robotsError struct {
+	URL string
+}
+
+func (e *robotsError) Error() string {
+	return fmt.Sprintf("%s: blocked by robots.txt", e.URL)
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (body *releaseBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.release)
+	return err
+}
+
+type retryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *retryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *retryError) Unwrap() error {
+	return e.Err
+}
+
+func retryAttempts(err error) int {
+	var retryErr *retryError
+	if errors.As(err, &retryErr) {
+		return retryErr.Attempts
+	}
+	return 1
+}
+
+func statusCode(err error) int {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var navigationErr *navigationError
+	if errors.As(err, &navigationErr) {
+		return transientNavigationErrors[navigationErr.Text]
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+func retryWait(err error) time.Duration {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+func retryBackoff(attempt int, wait time.Duration) time.Duration {
+	if wait > 0 {
+		if wait > maxRetryBackoff {
+			return maxRetryBackoff
+		}
+		return wait
+	}
+	backoff := retryBackoffBase() << uint(attempt)
+	if backoff > maxRetryBackoff || backoff <= 0 {
+		backoff = maxRetryBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func retryBackoffBase() time.Duration {
+	if settings.RetryBackoff != nil && *settings.RetryBackoff > 0 {
+		return time.Duration(*settings.RetryBackoff) * time.Millisecond
+	}
+	return 500 * time.Millisecond
+}
+
+func maxRetries() int {
+	if settings.Retries == nil {
+		return 3
+	}
+	return *settings.Retries
+}
+
+func fetchWithRetry(netClient *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, &retryError{Attempts: attempt + 1, Err: err}
+		}
+		release, err := acquireRequest(req.URL.String(), req.Header.Get("User-Agent"))
+		if err != nil {
+			return nil, &retryError{Attempts: attempt + 1, Err: err}
+		}
+		response, err := netClient.Do(req)
+		if err != nil {
+			release()
+		} else {
+			if response.StatusCode < 400 {
+				response.Body = &releaseBody{ReadCloser: response.Body, release: release}
+				return response, nil
+			}
+			err = &httpStatusError{
+				URL:        req.URL.String(),
+				StatusCode: response.StatusCode,
+				RetryAfter: retryAfter(response.Header.Get("Retry-After")),
+			}
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			_ = response.Body.Close()
+			release()
+		}
+		if attempt >= maxRetries() || !transientError(err) {
+			return nil, &retryError{Attempts: attempt + 1, Err: err}
+		}
+		time.Sleep(retryBackoff(attempt, retryWait(err)))
+	}
+}
+
+func getWithRetry(URL, userAgent string) (*http.Response, error) {
+	return fetchWithRetry(httpClient(), func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, URL, nil)
+		if err == nil && len(userAgent) > 0 {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		return req, err
+	})
+}
+
+func runWithRetry(pageURL, userAgent string, action func() error) error {
+	for attempt := 0; ; attempt++ {
+		release, err := acquireRequest(pageURL, userAgent)
+		if err != nil {
+			return &retryError{Attempts: attempt + 1, Err: err}
+		}
+		err = action()
+		release()
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxRetries() || !transientError(err) {
+			return &retryError{Attempts: attempt + 1, Err: err}
+		}
+		time.Sleep(retryBackoff(attempt, retryWait(err)))
+	}
+}
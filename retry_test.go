@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutError struct {
+	timeout bool
+}
+
+func (e timeoutError) Error() string   { return "timeout" }
+func (e timeoutError) Timeout() bool   { return e.timeout }
+func (e timeoutError) Temporary() bool { return e.timeout }
+
+func urlError(err error) error {
+	return &url.Error{Op: "Get", URL: "http://example.com/", Err: err}
+}
+
+func TestTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", &httpStatusError{StatusCode: 429}, true},
+		{"server error", &httpStatusError{StatusCode: 503}, true},
+		{"not found", &httpStatusError{StatusCode: 404}, false},
+		{"forbidden", &httpStatusError{StatusCode: 403}, false},
+		{"timeout", urlError(timeoutError{timeout: true}), true},
+		{"non-timeout net error", urlError(timeoutError{timeout: false}), false},
+		{"connection refused", urlError(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"connection reset", urlError(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"unexpected EOF", urlError(io.ErrUnexpectedEOF), true},
+		{"wrapped status", fmt.Errorf("fetch: %w", &httpStatusError{StatusCode: 502}), true},
+		{"unknown authority", urlError(x509.UnknownAuthorityError{}), false},
+		{"unsupported scheme", urlError(errors.New(`unsupported protocol scheme "ftp"`)), false},
+		{"browser connection reset", &navigationError{Text: "net::ERR_CONNECTION_RESET"}, true},
+		{"browser timeout", &navigationError{Text: "net::ERR_TIMED_OUT"}, true},
+		{"browser name not resolved", &navigationError{Text: "net::ERR_NAME_NOT_RESOLVED"}, false},
+		{"robots", &robotsError{URL: "http://example.com/"}, false},
+		{"canceled", urlError(context.Canceled), false},
+		{"plain", errors.New("boom"), false},
+	}
+	for _, test := range tests {
+		got := transientError(test.err)
+		if got != test.want {
+			t.Errorf("%s: transientError(%v) = %v, want %v", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("boom"), 1},
+		{&retryError{Attempts: 4, Err: errors.New("boom")}, 4},
+		{fmt.Errorf("wrapped: %w", &retryError{Attempts: 2, Err: errors.New("boom")}), 2},
+	}
+	for _, test := range tests {
+		if got := retryAttempts(test.err); got != test.want {
+			t.Errorf("retryAttempts(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	tests := []struct {
+		err  error
+		want time.Duration
+	}{
+		{&httpStatusError{StatusCode: 429, RetryAfter: retryAfter("3")}, 3 * time.Second},
+		{&retryError{Attempts: 2, Err: &httpStatusError{StatusCode: 503, RetryAfter: time.Second}}, time.Second},
+		{&httpStatusError{StatusCode: 503, RetryAfter: retryAfter("soon")}, 0},
+		{&navigationError{Text: "net::ERR_CONNECTION_RESET"}, 0},
+		{errors.New("boom"), 0},
+	}
+	for _, test := range tests {
+		if got := retryWait(test.err); got != test.want {
+			t.Errorf("retryWait(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRunWithRetry(t *testing.T) {
+	saved := settings
+	defer func() {
+		settings = saved
+		crawlRobots.reset()
+	}()
+	settings.Retries, settings.RetryBackoff = newInt(3), newInt(1)
+	crawlRobots.reset()
+	crawlRobots.hosts["http://example.com"] = &robotsRules{}
+	crawlRobots.hosts["http://blocked.example.com"] = disallowAll()
+	tests := []struct {
+		name  string
+		url   string
+		errs  []error
+		calls int
+		fails bool
+	}{
+		{"success", "http://example.com/", nil, 1, false},
+		{"transient then success", "http://example.com/", []error{
+			&navigationError{Text: "net::ERR_CONNECTION_RESET"},
+			&httpStatusError{StatusCode: 503},
+		}, 3, false},
+		{"retries exhausted", "http://example.com/", []error{
+			&httpStatusError{StatusCode: 502},
+			&httpStatusError{StatusCode: 502},
+			&httpStatusError{StatusCode: 502},
+			&httpStatusError{StatusCode: 502},
+		}, 4, true},
+		{"not found", "http://example.com/", []error{&httpStatusError{StatusCode: 404}}, 1, true},
+		{"wait timed out", "http://example.com/", []error{errors.New("wait for window.ready timed out")}, 1, true},
+		{"name not resolved", "http://example.com/", []error{&navigationError{Text: "net::ERR_NAME_NOT_RESOLVED"}}, 1, true},
+		{"canceled", "http://example.com/", []error{context.Canceled}, 1, true},
+		{"robots", "http://blocked.example.com/", nil, 0, true},
+	}
+	for _, test := range tests {
+		calls := 0
+		err := runWithRetry(test.url, "", func() error {
+			calls++
+			if calls <= len(test.errs) {
+				return test.errs[calls-1]
+			}
+			return nil
+		})
+		if calls != test.calls || (err != nil) != test.fails {
+			t.Errorf("%s: runWithRetry() made %d calls, error %v; want %d calls, failure %v", test.name, calls, err, test.calls, test.fails)
+		}
+		if err != nil && test.calls > 0 && retryAttempts(err) != test.calls {
+			t.Errorf("%s: retryAttempts() = %d, want %d", test.name, retryAttempts(err), test.calls)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	crawlRobots = newRobotsCache()
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRules struct {
+	groups []*robotsGroup
+}
+
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsRules
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsRules)}
+}
+
+func parseRobots(body io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	var group *robotsGroup
+	lastWasAgent := false
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:index]))
+		value := strings.TrimSpace(line[index+1:])
+		switch key {
+		case "user-agent":
+			if group == nil || !lastWasAgent {
+				group = &robotsGroup{}
+				rules.groups = append(rules.groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if group != nil && value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)})
+			}
+		case "crawl-delay":
+			if group != nil {
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+	return rules
+}
+
+func (rules *robotsRules) group(userAgent string) *robotsGroup {
+	userAgent = strings.ToLower(userAgent)
+	var best, wildcard *robotsGroup
+	bestLength := 0
+	for _, group := range rules.groups {
+		for _, agent := range group.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = group
+				}
+			} else if userAgent != "" && strings.Contains(userAgent, agent) && len(agent) > bestLength {
+				best = group
+				bestLength = len(agent)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return wildcard
+}
+
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	expression := "^" + strings.Replace(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*", -1)
+	if anchored {
+		expression += "$"
+	}
+	return regexp.MustCompile(expression)
+}
+
+func (group *robotsGroup) allowed(path string) bool {
+	allowed := true
+	matched := -1
+	for _, rule := range group.rules {
+		if rule.re.MatchString(path) {
+			if len(rule.pattern) > matched || (len(rule.pattern) == matched && rule.allow) {
+				allowed = rule.allow
+				matched = len(rule.pattern)
+			}
+		}
+	}
+	return allowed
+}
+
+func disallowAll() *robotsRules {
+	return &robotsRules{groups: []*robotsGroup{{agents: []string{"*"}, rules: []robotsRule{{pattern: "/", re: robotsPattern("/")}}}}}
+}
+
+func fetchRobots(host string) *robotsRules {
+	response, err := getWithRetry(host+"/robots.txt", "")
+	if err != nil {
+		code := statusCode(err)
+		if code >= 400 && code < 500 {
+			return &robotsRules{}
+		}
+		logErrors(err)
+		return disallowAll()
+	}
+	defer response.Body.Close()
+	return parseRobots(io.LimitReader(response.Body, 500*1024))
+}
+
+func (cache *robotsCache) rules(host string) *robotsRules {
+	cache.mu.Lock()
+	rules, ok := cache.hosts[host]
+	cache.mu.Unlock()
+	if ok {
+		return rules
+	}
+	rules = fetchRobots(host)
+	cache.mu.Lock()
+	cache.hosts[host] = rules
+	cache.mu.Unlock()
+	return rules
+}
+
+func (cache *robotsCache) check(pageURL, userAgent string) (bool, time.Duration) {
+	uri, err := url.Parse(pageURL)
+	if err != nil {
+		return false, 0
+	}
+	group := cache.rules(uri.Scheme + "://" + uri.Host).group(userAgent)
+	if group == nil {
+		return true, 0
+	}
+	path := uri.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if uri.RawQuery != "" {
+		path += "?" + uri.RawQuery
+	}
+	return group.allowed(path), group.crawlDelay
+}
+
+func (cache *robotsCache) reset() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.hosts = make(map[string]*robotsRules)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: Scraper
+User-agent: OtherBot
+Disallow: /
+Allow: /open
+
+User-agent: Slow
+Crawl-delay: 0.5
+Disallow:
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots))
+	tests := []struct {
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{"", "/", true},
+		{"", "/private", false},
+		{"", "/private/page", false},
+		{"", "/private/public", true},
+		{"", "/private/public/page", true},
+		{"", "/files/report.pdf", false},
+		{"", "/files/report.pdf?x=1", true},
+		{"Mozilla/5.0 Scraper/1.0", "/page", false},
+		{"Mozilla/5.0 Scraper/1.0", "/open/page", true},
+		{"otherbot", "/private/public", false},
+		{"Slow", "/private", true},
+	}
+	for _, test := range tests {
+		group := rules.group(test.userAgent)
+		if group == nil {
+			t.Fatalf("group(%q) = nil", test.userAgent)
+		}
+		got := group.allowed(test.path)
+		if got != test.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", test.userAgent, test.path, got, test.want)
+		}
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots))
+	tests := []struct {
+		userAgent string
+		want      time.Duration
+	}{
+		{"", 2 * time.Second},
+		{"Scraper", 0},
+		{"slow", 500 * time.Millisecond},
+	}
+	for _, test := range tests {
+		got := rules.group(test.userAgent).crawlDelay
+		if got != test.want {
+			t.Errorf("crawlDelay(%q) = %v, want %v", test.userAgent, got, test.want)
+		}
+	}
+}
+
+func TestRobotsNoGroups(t *testing.T) {
+	if group := parseRobots(strings.NewReader("Disallow: /\n")).group("any"); group != nil {
+		t.Errorf("rules outside a user-agent group were applied: %v", group)
+	}
+	if disallowAll().group("any").allowed("/page") {
+		t.Error("disallowAll() allowed /page")
+	}
+}
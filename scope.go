@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/dlclark/regexp2"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	crawlScope = &scope{}
+)
+
+type scope struct {
+	mu       sync.Mutex
+	pages    int
+	maxPages int
+	maxDepth int
+	domains  []string
+	include  []*regexp2.Regexp
+	exclude  []*regexp2.Regexp
+}
+
+func compilePatterns(patterns []string) ([]*regexp2.Regexp, error) {
+	var compiled []*regexp2.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp2.Compile(pattern, 0)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func newScope(siteMap scraping) (*scope, error) {
+	var err error
+	s := &scope{}
+	if siteMap.MaxPages != nil {
+		s.maxPages = *siteMap.MaxPages
+	}
+	if siteMap.MaxDepth != nil {
+		s.maxDepth = *siteMap.MaxDepth
+	}
+	for _, domain := range siteMap.AllowedDomains {
+		domain = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*"), ".")
+		if domain != "" {
+			s.domains = append(s.domains, domain)
+		}
+	}
+	s.include, err = compilePatterns(siteMap.IncludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	s.exclude, err = compilePatterns(siteMap.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *scope) allows(link string, depth int) bool {
+	if s.maxDepth > 0 && depth > s.maxDepth {
+		return false
+	}
+	if len(s.domains) > 0 {
+		uri, err := url.Parse(link)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(uri.Hostname())
+		allowed := false
+		for _, domain := range s.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if len(s.include) > 0 {
+		included := false
+		for _, re := range s.include {
+			if match, _ := re.MatchString(link); match {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, re := range s.exclude {
+		if match, _ := re.MatchString(link); match {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scope) filter(links []string, depth int) []string {
+	var allowed []string
+	for _, link := range links {
+		if validURL(link) && s.allows(link, depth) {
+			allowed = append(allowed, link)
+		}
+	}
+	return allowed
+}
+
+func (s *scope) reserve() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxPages > 0 && s.pages >= s.maxPages {
+		return false
+	}
+	s.pages++
+	return true
+}
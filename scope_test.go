@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	s, err := newScope(scraping{
+		MaxDepth:        newInt(2),
+		AllowedDomains:  []string{"*.example.com", "Shop.Test"},
+		IncludePatterns: []string{`/(products|catalog)/`},
+		ExcludePatterns: []string{`\?print=1`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		link  string
+		depth int
+		want  bool
+	}{
+		{"http://example.com/products/1", 1, true},
+		{"http://www.example.com/catalog/2", 2, true},
+		{"http://shop.test/products/3", 0, true},
+		{"http://example.com/products/1", 3, false},
+		{"http://notexample.com/products/1", 1, false},
+		{"http://other.test/products/1", 1, false},
+		{"http://example.com/about", 1, false},
+		{"http://example.com/products/1?print=1", 1, false},
+	}
+	for _, test := range tests {
+		got := s.allows(test.link, test.depth)
+		if got != test.want {
+			t.Errorf("allows(%q, %d) = %v, want %v", test.link, test.depth, got, test.want)
+		}
+	}
+}
+
+func TestScopeReserve(t *testing.T) {
+	s, err := newScope(scraping{MaxPages: newInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, true, false, false} {
+		if got := s.reserve(); got != want {
+			t.Errorf("reserve() #%d = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestNewScopeInvalidPattern(t *testing.T) {
+	_, err := newScope(scraping{IncludePatterns: []string{`(`}})
+	if err == nil {
+		t.Error("newScope() accepted an invalid include pattern")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"math"
+)
+
+func fullPageScreenshot(buf *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, contentSize, err := page.GetLayoutMetrics().Do(ctx)
+		if err != nil {
+			return err
+		}
+		width, height := int64(math.Ceil(contentSize.Width)), int64(math.Ceil(contentSize.Height))
+		err = emulation.SetDeviceMetricsOverride(width, height, 1, false).Do(ctx)
+		if err != nil {
+			return err
+		}
+		*buf, err = page.CaptureScreenshot().WithClip(&page.Viewport{
+			Width:  contentSize.Width,
+			Height: contentSize.Height,
+			Scale:  1,
+		}).Do(ctx)
+		clearErr := emulation.ClearDeviceMetricsOverride().Do(ctx)
+		if err == nil {
+			err = clearErr
+		}
+		return err
+	})
+}
+
+func printPDF(buf *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		*buf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+		return err
+	})
+}
+
+func saveCapture(key, contentType string, data []byte) (assetRecord, error) {
+	store, err := assetStorage()
+	if err != nil {
+		return assetRecord{}, err
+	}
+	return store.save(key, contentType, bytes.NewReader(data))
+}
+
+func captureScreenshot(ctx context.Context, selector *selectors, png, pdf *[]byte) error {
+	err := compileXPath(selector, selector.Selector)
+	if err != nil {
+		return err
+	}
+	capture := fullPageScreenshot(png)
+	if selector.Selector != "" {
+		capture = chromedp.ActionFunc(func(ctx context.Context) error {
+			var query string
+			err := markXPath(selector, selector.Selector, &query).Do(ctx)
+			if err != nil {
+				return err
+			}
+			return chromedp.Screenshot(query, png, chromedp.NodeVisible, chromedp.ByQuery).Do(ctx)
+		})
+	}
+	actions := []chromedp.Action{capture}
+	if selector.PDF != nil && *selector.PDF {
+		actions = append(actions, printPDF(pdf))
+	}
+	return chromedp.Run(ctx, actions...)
+}
+
+func selectorScreenshot(job *workerJob, selector *selectors) (interface{}, error) {
+	action := job.pageAction(selector)
+	if action.err != nil {
+		return nil, action.err
+	}
+	key := "screenshot:" + job.startURL + "#" + selector.ID
+	record, err := saveCapture(key+".png", "image/png", action.png)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]interface{}{"url": job.startURL, "hash": record.Hash, "path": record.Path}
+	if len(action.pdf) > 0 {
+		record, err = saveCapture(key+".pdf", "application/pdf", action.pdf)
+		if err != nil {
+			return output, err
+		}
+		output["pdfHash"], output["pdfPath"] = record.Hash, record.Path
+	}
+	return output, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"time"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	waitPollInterval   = 100 * time.Millisecond
+)
+
+type waitCondition struct {
+	Type       string `json:"type,omitempty"`
+	Selector   string `json:"selector,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Timeout    *int   `json:"timeout,omitempty"`
+}
+
+func (condition *waitCondition) timeout() time.Duration {
+	if condition.Timeout == nil || *condition.Timeout <= 0 {
+		return defaultWaitTimeout
+	}
+	return time.Duration(*condition.Timeout) * time.Millisecond
+}
+
+func pageWaits(siteMap *scraping, parent string) []*waitCondition {
+	var conditions []*waitCondition
+	if siteMap.Wait != nil && siteMap.Wait.Type != "" {
+		conditions = append(conditions, siteMap.Wait)
+	}
+	for _, selector := range siteMap.Selectors {
+		if hasParent(&selector, parent) {
+			if selector.Wait != nil && selector.Wait.Type != "" {
+				conditions = append(conditions, selector.Wait)
+			}
+		}
+	}
+	return conditions
+}
+
+func watchNetworkIdle(ctx context.Context) (<-chan struct{}, context.CancelFunc) {
+	idle := make(chan struct{})
+	started, closed := false, false
+	listenCtx, cancel := context.WithCancel(ctx)
+	chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+		lifecycle, ok := ev.(*page.EventLifecycleEvent)
+		if !ok {
+			return
+		}
+		switch lifecycle.Name {
+		case "init":
+			started = true
+		case "networkIdle":
+			if started && !closed {
+				closed = true
+				close(idle)
+			}
+		}
+	})
+	return idle, cancel
+}
+
+func waitExpression(ctx context.Context, expression string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var ready bool
+		err := chromedp.Evaluate(`!!(`+expression+`)`, &ready).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait for %s timed out", expression)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitPollInterval):
+		}
+	}
+}
+
+func waitActions(conditions []*waitCondition, idle <-chan struct{}) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, condition := range conditions {
+			var err error
+			switch condition.Type {
+			case "selector":
+				query, _ := json.Marshal(condition.Selector)
+				err = waitExpression(ctx, `document.querySelector(`+string(query)+`)`, condition.timeout())
+			case "networkIdle":
+				select {
+				case <-ctx.Done():
+					err = ctx.Err()
+				case <-idle:
+				case <-time.After(condition.timeout()):
+				}
+			case "expression":
+				err = waitExpression(ctx, condition.Expression, condition.timeout())
+			case "timeout":
+				err = chromedp.Sleep(condition.timeout()).Do(ctx)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func snapshotHTML(fullHTML bool, html *string) chromedp.Action {
+	if fullHTML {
+		return chromedp.Evaluate(`document.documentElement.outerHTML`, html)
+	}
+	return chromedp.InnerHTML(`body`, html, chromedp.NodeVisible, chromedp.ByQuery)
+}
+
+func enableLifecycleEvents() chromedp.Action {
+	return page.SetLifecycleEventsEnabled(true)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	selectorKindCSS   = "css"
+	selectorKindXPath = "xpath"
+)
+
+var (
+	xpathMarks int64
+)
+
+func isXPath(selector *selectors) bool {
+	return strings.EqualFold(strings.TrimSpace(selector.SelectorKind), selectorKindXPath)
+}
+
+func selectorFind(scope *goquery.Selection, selector *selectors, query string) *goquery.Selection {
+	if !isXPath(selector) {
+		return scope.Find(query)
+	}
+	var nodes []*html.Node
+	for _, node := range scope.Nodes {
+		found, err := htmlquery.QueryAll(node, query)
+		if err != nil {
+			logErrors(fmt.Errorf("%s: invalid XPath %q: %v", selector.ID, query, err))
+			break
+		}
+		nodes = append(nodes, found...)
+	}
+	// Eq past the end gives an empty selection that doesn't share the scope's backing array.
+	return scope.Eq(scope.Length()).AddNodes(nodes...)
+}
+
+func compileXPath(selector *selectors, query string) error {
+	if !isXPath(selector) || query == "" {
+		return nil
+	}
+	_, err := xpath.Compile(query)
+	if err != nil {
+		return fmt.Errorf("%s: invalid XPath %q: %v", selector.ID, query, err)
+	}
+	return nil
+}
+
+func markXPath(selector *selectors, query string, css *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		*css = query
+		if !isXPath(selector) {
+			return nil
+		}
+		expression, _ := json.Marshal(query)
+		mark := strconv.FormatInt(atomic.AddInt64(&xpathMarks, 1), 10)
+		var count int
+		err := chromedp.Evaluate(`(() => {
+			const result = document.evaluate(`+string(expression)+`, document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+			for (let i = 0; i < result.snapshotLength; i++) {
+				const node = result.snapshotItem(i);
+				if (node.nodeType === Node.ELEMENT_NODE) {
+					node.setAttribute("data-scraper-xpath", "`+mark+`");
+				}
+			}
+			return result.snapshotLength;
+		})()`, &count).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: invalid XPath %q: %v", selector.ID, query, err)
+		}
+		*css = `[data-scraper-xpath="` + mark + `"]`
+		return nil
+	})
+}
+
+func validateSelectors(siteMap scraping) error {
+	for _, selector := range siteMap.Selectors {
+		if selector.Type == "SelectorNetworkResponse" && (settings.JavaScript == nil || !*settings.JavaScript) {
+			return fmt.Errorf("%s: network responses can only be captured in JavaScript mode", selector.ID)
+		}
+		kind := strings.ToLower(strings.TrimSpace(selector.SelectorKind))
+		if kind != "" && kind != selectorKindCSS && kind != selectorKindXPath {
+			return fmt.Errorf("%s: unknown selector kind %q", selector.ID, selector.SelectorKind)
+		}
+		if kind != selectorKindXPath {
+			continue
+		}
+		for _, query := range []string{selector.Selector, selector.HeaderRowSelector, selector.DataRowsSelector, selector.ClickSelector} {
+			err := compileXPath(&selector, query)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testXPathPage = `<html><body>
+	<dl><dt>Name</dt><dd>Widget</dd><dt>Price</dt><dd>12</dd></dl>
+	<div class="item"><a href="/a">A</a></div>
+	<div class="item"><a href="/b">B</a></div>
+</body></html>`
+
+func TestSelectorFindXPath(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testXPathPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		kind  string
+		query string
+		want  []string
+	}{
+		{"xpath", "//dt[text()='Price']/following-sibling::dd[1]", []string{"12"}},
+		{"xpath", "//div[@class='item']/a", []string{"A", "B"}},
+		{"xpath", "//a/@href", []string{"/a", "/b"}},
+		{"XPath", "//dd[last()]", []string{"12"}},
+		{"xpath", "//[", nil},
+		{"css", "div.item a", []string{"A", "B"}},
+		{"", "dd", []string{"Widget", "12"}},
+	}
+	for _, test := range tests {
+		selector := &selectors{ID: "test", SelectorKind: test.kind, Selector: test.query}
+		var got []string
+		selectorFind(doc.Selection, selector, test.query).Each(func(_ int, s *goquery.Selection) {
+			got = append(got, s.Text())
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("selectorFind(%s %q) = %v, want %v", test.kind, test.query, got, test.want)
+		}
+	}
+	if doc.Find("dl").Length() != 1 || len(doc.Nodes) != 1 {
+		t.Error("selectorFind modified the document selection")
+	}
+}
+
+func TestSelectorFindXPathScope(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testXPathPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector := &selectors{ID: "link", SelectorKind: "xpath", Selector: "./a"}
+	var got []string
+	doc.Find("div.item").Each(func(_ int, item *goquery.Selection) {
+		got = append(got, selectorFind(item, selector, selector.Selector).AttrOr("href", ""))
+	})
+	if want := []string{"/a", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scoped selectorFind = %v, want %v", got, want)
+	}
+}
+
+func TestValidateSelectors(t *testing.T) {
+	tests := []struct {
+		selector selectors
+		valid    bool
+	}{
+		{selectors{ID: "css", Selector: "div > a"}, true},
+		{selectors{ID: "xpath", SelectorKind: "xpath", Selector: "//div/a"}, true},
+		{selectors{ID: "bad", SelectorKind: "xpath", Selector: "//div["}, false},
+		{selectors{ID: "rows", SelectorKind: "xpath", Selector: "//table", DataRowsSelector: ".//tr[", HeaderRowSelector: ".//thead/tr"}, false},
+		{selectors{ID: "kind", SelectorKind: "jq", Selector: "."}, false},
+	}
+	for _, test := range tests {
+		err := validateSelectors(scraping{Selectors: []selectors{test.selector}})
+		if (err == nil) != test.valid {
+			t.Errorf("validateSelectors(%s) error = %v, want valid %v", test.selector.ID, err, test.valid)
+		}
+	}
+}
+
+func TestValidateSelectorsNetworkResponse(t *testing.T) {
+	saved := settings
+	defer func() { settings = saved }()
+	siteMap := scraping{Selectors: []selectors{{ID: "api", Type: "SelectorNetworkResponse", ResponseURLRegex: "/api/"}}}
+	for _, javaScript := range []bool{false, true} {
+		settings.JavaScript = newBool(javaScript)
+		err := validateSelectors(siteMap)
+		if (err == nil) != javaScript {
+			t.Errorf("validateSelectors() with javaScript %v error = %v", javaScript, err)
+		}
+	}
+}